@@ -2,16 +2,32 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+// Dialect identifies the SQL database in use
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
 )
 
 var DB *sql.DB
 
-// Connect establishes connection to PostgreSQL database
+// CurrentDialect is the dialect of DB; only meaningful when connected
+var CurrentDialect Dialect
+
+// Connect opens the database named by DATABASE_URL: sqlite://path/to/file.db
+// for an embedded SQLite file, or a postgres:// URL for PostgreSQL
 func Connect() error {
 	databaseURL := os.Getenv("DATABASE_URL")
 	if databaseURL == "" {
@@ -19,11 +35,20 @@ func Connect() error {
 		return nil
 	}
 
+	if path, ok := strings.CutPrefix(databaseURL, "sqlite://"); ok {
+		return connectSQLite(path)
+	}
+	return connectPostgres(databaseURL)
+}
+
+// connectPostgres establishes connection to PostgreSQL database
+func connectPostgres(databaseURL string) error {
 	var err error
 	DB, err = sql.Open("postgres", databaseURL)
 	if err != nil {
 		return err
 	}
+	CurrentDialect = Postgres
 
 	// Configure connection pool
 	DB.SetMaxOpenConns(25)
@@ -41,9 +66,41 @@ func Connect() error {
 		time.Sleep(2 * time.Second)
 	}
 
+	// Leave DB unset so callers fall back to in-memory storage
+	DB.Close()
+	DB = nil
 	return err
 }
 
+// connectSQLite opens a SQLite database file, creating it if needed
+func connectSQLite(path string) error {
+	if path == "" {
+		return fmt.Errorf("sqlite:// URL must include a file path")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create database directory: %w", err)
+		}
+	}
+
+	// Foreign keys are off by default in SQLite and must be enabled per connection.
+	// Immediate transactions take the write lock up front, so concurrent
+	// processes wait instead of failing halfway through a migration.
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return err
+	}
+
+	// SQLite allows a single writer; one connection avoids "database is locked" errors
+	db.SetMaxOpenConns(1)
+
+	DB = db
+	CurrentDialect = SQLite
+	log.Printf("Connected to SQLite database at %s", path)
+	return nil
+}
+
 // Close closes the database connection
 func Close() {
 	if DB != nil {
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS trigger_update_session_activity ON chat_messages;
+DROP FUNCTION IF EXISTS update_session_activity();
+DROP TABLE IF EXISTS chat_messages;
+DROP TABLE IF EXISTS documents;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -1,5 +1,6 @@
--- AskMyPDF Database Schema
--- Auto-executed when PostgreSQL container starts
+-- Initial schema
+-- Written with IF NOT EXISTS so databases created before migrations existed
+-- (by the container init script) can adopt it
 
 -- Enable UUID extension
 CREATE EXTENSION IF NOT EXISTS "pgcrypto";
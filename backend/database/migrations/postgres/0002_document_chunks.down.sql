@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS document_chunks;
+ALTER TABLE documents DROP COLUMN IF EXISTS text;
@@ -0,0 +1,15 @@
+-- Store extracted text and chunks so documents can be restored without re-parsing
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS text TEXT;
+
+CREATE TABLE IF NOT EXISTS document_chunks (
+    id UUID PRIMARY KEY,
+    document_id UUID REFERENCES documents(id) ON DELETE CASCADE,
+    chunk_index INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    start_page INTEGER NOT NULL,
+    end_page INTEGER NOT NULL,
+    embedding REAL[],
+    UNIQUE (document_id, chunk_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_document_chunks_document_id ON document_chunks(document_id);
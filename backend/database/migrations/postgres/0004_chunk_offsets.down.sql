@@ -0,0 +1,2 @@
+ALTER TABLE document_chunks DROP COLUMN IF EXISTS end_offset;
+ALTER TABLE document_chunks DROP COLUMN IF EXISTS start_offset;
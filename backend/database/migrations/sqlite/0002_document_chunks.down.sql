@@ -0,0 +1 @@
+-- Chunks were part of the initial SQLite schema; see 0001_initial.down.sql
@@ -0,0 +1 @@
+-- Chunks were part of the initial SQLite schema; kept so versions line up with PostgreSQL
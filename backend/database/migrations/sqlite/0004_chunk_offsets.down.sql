@@ -0,0 +1,2 @@
+ALTER TABLE document_chunks DROP COLUMN end_offset;
+ALTER TABLE document_chunks DROP COLUMN start_offset;
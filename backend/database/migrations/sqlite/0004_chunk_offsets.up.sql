@@ -0,0 +1,3 @@
+-- Record where each chunk starts and ends within its pages, so citations can point at the exact passage
+ALTER TABLE document_chunks ADD COLUMN start_offset INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE document_chunks ADD COLUMN end_offset INTEGER NOT NULL DEFAULT 0;
@@ -14,11 +14,11 @@ import (
 
 // AuthHandler handles authentication requests
 type AuthHandler struct {
-	userRepo *repositories.UserRepository
+	userRepo repositories.UserStore
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(userRepo *repositories.UserRepository) *AuthHandler {
+func NewAuthHandler(userRepo repositories.UserStore) *AuthHandler {
 	return &AuthHandler{userRepo: userRepo}
 }
 
@@ -93,7 +93,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	// Verify password
-	if !h.userRepo.VerifyPassword(user, req.Password) {
+	if !repositories.VerifyPassword(user, req.Password) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
@@ -1,37 +1,35 @@
 package handlers
 
 import (
-	"ai-pdf-assistant-backend/infrastructure/repositories"
 	"ai-pdf-assistant-backend/proto"
 	"ai-pdf-assistant-backend/usecases"
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 // ChatHandler handles chat-related HTTP requests
 type ChatHandler struct {
-	chatUseCase     *usecases.ChatUseCase
-	persistenceRepo *repositories.PersistenceRepository
+	chatUseCase *usecases.ChatUseCase
 }
 
 // NewChatHandler creates a new chat handler
-func NewChatHandler(chatUseCase *usecases.ChatUseCase, persistenceRepo *repositories.PersistenceRepository) *ChatHandler {
+func NewChatHandler(chatUseCase *usecases.ChatUseCase) *ChatHandler {
 	return &ChatHandler{
-		chatUseCase:     chatUseCase,
-		persistenceRepo: persistenceRepo,
+		chatUseCase: chatUseCase,
 	}
 }
 
 // Message handles chat message requests
 func (h *ChatHandler) Message(c *gin.Context) {
 	var jsonReq struct {
-		SessionID string `json:"session_id" binding:"required"`
-		Message   string `json:"message" binding:"required"`
+		SessionID     string   `json:"session_id" binding:"required"`
+		Message       string   `json:"message" binding:"required"`
+		TopK          int32    `json:"top_k" binding:"omitempty,min=1,max=100"`
+		KeywordWeight *float64 `json:"keyword_weight" binding:"omitempty,min=0"`
+		DenseWeight   *float64 `json:"dense_weight" binding:"omitempty,min=0"`
+		RewriteQuery  *bool    `json:"rewrite_query"`
 	}
 
 	if err := c.ShouldBindJSON(&jsonReq); err != nil {
@@ -43,12 +41,16 @@ func (h *ChatHandler) Message(c *gin.Context) {
 
 	// Convert JSON to Protobuf
 	req := &proto.ChatRequest{
-		SessionId: jsonReq.SessionID,
-		Message:   jsonReq.Message,
+		SessionId:     jsonReq.SessionID,
+		Message:       jsonReq.Message,
+		TopK:          jsonReq.TopK,
+		KeywordWeight: jsonReq.KeywordWeight,
+		DenseWeight:   jsonReq.DenseWeight,
+		RewriteQuery:  jsonReq.RewriteQuery,
 	}
 
 	// Call use case
-	resp, err := h.chatUseCase.AskQuestion(req)
+	resp, err := h.chatUseCase.AskQuestion(c.Request.Context(), req)
 	if err != nil {
 		fmt.Printf("ERROR: Chat AskQuestion failed: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -60,49 +62,25 @@ func (h *ChatHandler) Message(c *gin.Context) {
 	// Convert Protobuf to JSON
 	if resp.Status != proto.Status_STATUS_SUCCESS {
 		fmt.Printf("ERROR: Chat response status=%v code=%s msg=%s\n", resp.Status, resp.Error.Code, resp.Error.Message)
-		statusCode := http.StatusInternalServerError
-		if resp.Status == proto.Status_STATUS_NOT_FOUND {
-			statusCode = http.StatusNotFound
-		}
-		c.JSON(statusCode, gin.H{
+		c.JSON(errorStatusCode(resp.Status, resp.Error.Code), gin.H{
 			"error": resp.Error.Message,
 			"code":  resp.Error.Code,
 		})
 		return
 	}
 
-	// Persist messages to database if user is authenticated
-	if _, exists := c.Get("userID"); exists {
-		// Save user message
-		h.persistenceRepo.SaveMessage(&repositories.DBMessage{
-			ID:        uuid.New().String(),
-			SessionID: jsonReq.SessionID,
-			Role:      "user",
-			Content:   jsonReq.Message,
-			CreatedAt: time.Now(),
-		})
-
-		// Save AI response
-		var citationsJSON json.RawMessage
-		if resp.Citations != nil {
-			citationsJSON, _ = json.Marshal(resp.Citations)
-		}
-		h.persistenceRepo.SaveMessage(&repositories.DBMessage{
-			ID:        uuid.New().String(),
-			SessionID: jsonReq.SessionID,
-			Role:      "assistant",
-			Content:   resp.Response,
-			Citations: citationsJSON,
-			CreatedAt: time.Now(),
-		})
-	}
-
 	c.JSON(http.StatusOK, gin.H{
-		"response":        resp.Response,
-		"session_id":      resp.SessionId,
-		"answer_found":    resp.AnswerFound,
-		"relevant_chunks": resp.RelevantChunks,
-		"citations":       resp.Citations,
+		"response":         resp.Response,
+		"session_id":       resp.SessionId,
+		"answer_found":     resp.AnswerFound,
+		"confidence":       resp.Confidence,
+		"relevant_chunks":  resp.RelevantChunks,
+		"retrieved_chunks": resp.RetrievedChunks,
+		"citations":        resp.Citations,
+		"sentences":        resp.Sentences,
+		"provider":         resp.Provider,
+		"model":            resp.Model,
+		"rewritten_query":  resp.RewrittenQuery,
 	})
 }
 
@@ -114,7 +92,7 @@ func (h *ChatHandler) History(c *gin.Context) {
 		SessionId: sessionID,
 	}
 
-	resp, err := h.chatUseCase.GetHistory(req.SessionId)
+	resp, err := h.chatUseCase.GetHistory(c.Request.Context(), req.SessionId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to get history: " + err.Error(),
@@ -142,13 +120,19 @@ func (h *ChatHandler) History(c *gin.Context) {
 		}
 	}
 
+	// A restored session may have lost its documents if the files are gone
+	pdfInfo := gin.H{}
+	if doc := resp.Session.Document; doc != nil {
+		pdfInfo = gin.H{
+			"filename": doc.Filename,
+			"pages":    doc.Pages,
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"session_id": resp.Session.Id,
 		"messages":   messages,
-		"pdf_info": gin.H{
-			"filename": resp.Session.Document.Filename,
-			"pages":    resp.Session.Document.Pages,
-		},
+		"pdf_info":   pdfInfo,
 	})
 }
 
@@ -156,7 +140,7 @@ func (h *ChatHandler) History(c *gin.Context) {
 func (h *ChatHandler) ClearSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
-	resp, err := h.chatUseCase.ClearSession(sessionID)
+	resp, err := h.chatUseCase.ClearSession(c.Request.Context(), sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to clear session: " + err.Error(),
@@ -182,8 +166,12 @@ func (h *ChatHandler) ClearSession(c *gin.Context) {
 // Stream handles SSE streaming chat requests
 func (h *ChatHandler) Stream(c *gin.Context) {
 	var jsonReq struct {
-		SessionID string `json:"session_id" binding:"required"`
-		Message   string `json:"message" binding:"required"`
+		SessionID     string   `json:"session_id" binding:"required"`
+		Message       string   `json:"message" binding:"required"`
+		TopK          int32    `json:"top_k" binding:"omitempty,min=1,max=100"`
+		KeywordWeight *float64 `json:"keyword_weight" binding:"omitempty,min=0"`
+		DenseWeight   *float64 `json:"dense_weight" binding:"omitempty,min=0"`
+		RewriteQuery  *bool    `json:"rewrite_query"`
 	}
 
 	if err := c.ShouldBindJSON(&jsonReq); err != nil {
@@ -201,12 +189,20 @@ func (h *ChatHandler) Stream(c *gin.Context) {
 
 	// Convert JSON to Protobuf
 	req := &proto.ChatRequest{
-		SessionId: jsonReq.SessionID,
-		Message:   jsonReq.Message,
+		SessionId:     jsonReq.SessionID,
+		Message:       jsonReq.Message,
+		TopK:          jsonReq.TopK,
+		KeywordWeight: jsonReq.KeywordWeight,
+		DenseWeight:   jsonReq.DenseWeight,
+		RewriteQuery:  jsonReq.RewriteQuery,
 	}
 
-	// Get response from use case (non-streaming for now, but we'll stream to client)
-	resp, err := h.chatUseCase.AskQuestion(req)
+	// Forward answer tokens to the client as the AI service generates them
+	resp, err := h.chatUseCase.StreamQuestion(c.Request.Context(), req, func(token string) error {
+		c.SSEvent("token", gin.H{"content": token})
+		c.Writer.Flush()
+		return nil
+	})
 	if err != nil {
 		c.SSEvent("error", gin.H{"message": "Failed to process message: " + err.Error()})
 		return
@@ -217,67 +213,18 @@ func (h *ChatHandler) Stream(c *gin.Context) {
 		return
 	}
 
-	// Stream the response word by word
-	words := splitIntoChunks(resp.Response)
-	for _, word := range words {
-		c.SSEvent("token", gin.H{"content": word})
-		c.Writer.Flush()
-		time.Sleep(20 * time.Millisecond) // Small delay for streaming effect
-	}
-
 	// Send completion event with citations
 	c.SSEvent("done", gin.H{
-		"response":     resp.Response,
-		"session_id":   resp.SessionId,
-		"answer_found": resp.AnswerFound,
-		"citations":    resp.Citations,
+		"response":         resp.Response,
+		"session_id":       resp.SessionId,
+		"answer_found":     resp.AnswerFound,
+		"confidence":       resp.Confidence,
+		"retrieved_chunks": resp.RetrievedChunks,
+		"citations":        resp.Citations,
+		"sentences":        resp.Sentences,
+		"provider":         resp.Provider,
+		"model":            resp.Model,
+		"rewritten_query":  resp.RewrittenQuery,
 	})
 	c.Writer.Flush()
 }
-
-// splitIntoChunks splits text into chunks for streaming
-func splitIntoChunks(text string) []string {
-	var chunks []string
-	words := make([]string, 0)
-
-	// Split by spaces but keep punctuation attached
-	currentWord := ""
-	for _, char := range text {
-		if char == ' ' || char == '\n' {
-			if currentWord != "" {
-				words = append(words, currentWord)
-				currentWord = ""
-			}
-			if char == '\n' {
-				words = append(words, "\n")
-			} else {
-				words = append(words, " ")
-			}
-		} else {
-			currentWord += string(char)
-		}
-	}
-	if currentWord != "" {
-		words = append(words, currentWord)
-	}
-
-	// Group into chunks of ~3-5 words for smoother streaming
-	chunk := ""
-	wordCount := 0
-	for _, word := range words {
-		chunk += word
-		if word != " " && word != "\n" {
-			wordCount++
-		}
-		if wordCount >= 3 {
-			chunks = append(chunks, chunk)
-			chunk = ""
-			wordCount = 0
-		}
-	}
-	if chunk != "" {
-		chunks = append(chunks, chunk)
-	}
-
-	return chunks
-}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+
+	"ai-pdf-assistant-backend/proto"
+	"ai-pdf-assistant-backend/usecases"
+)
+
+// statusClientClosedRequest is the de facto status for requests the client abandoned
+const statusClientClosedRequest = 499
+
+// errorStatusCode maps a failed use case response to an HTTP status code
+func errorStatusCode(status proto.Status, code string) int {
+	switch {
+	case status == proto.Status_STATUS_NOT_FOUND:
+		return http.StatusNotFound
+	case status == proto.Status_STATUS_PROCESSING:
+		return http.StatusConflict
+	case code == "INVALID_RETRIEVAL_CONFIG" || code == "INVALID_SUMMARY_MODE":
+		return http.StatusBadRequest
+	case code == usecases.ErrCodeRequestCancelled:
+		return statusClientClosedRequest
+	case code == usecases.ErrCodeAITimeout:
+		return http.StatusGatewayTimeout
+	case code == "QUEUE_FULL":
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
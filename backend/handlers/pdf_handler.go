@@ -2,11 +2,9 @@ package handlers
 
 import (
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"time"
 
 	"ai-pdf-assistant-backend/infrastructure/repositories"
 	"ai-pdf-assistant-backend/proto"
@@ -17,15 +15,13 @@ import (
 
 // PDFHandler handles PDF-related HTTP requests
 type PDFHandler struct {
-	pdfUseCase      *usecases.PDFUseCase
-	persistenceRepo *repositories.PersistenceRepository
+	pdfUseCase *usecases.PDFUseCase
 }
 
 // NewPDFHandler creates a new PDF handler
-func NewPDFHandler(pdfUseCase *usecases.PDFUseCase, persistenceRepo *repositories.PersistenceRepository) *PDFHandler {
+func NewPDFHandler(pdfUseCase *usecases.PDFUseCase) *PDFHandler {
 	return &PDFHandler{
-		pdfUseCase:      pdfUseCase,
-		persistenceRepo: persistenceRepo,
+		pdfUseCase: pdfUseCase,
 	}
 }
 
@@ -58,9 +54,9 @@ func (h *PDFHandler) Upload(c *gin.Context) {
 		})
 		return
 	}
-	defer out.Close()
 
 	_, err = io.Copy(out, file)
+	out.Close() // Processing happens in the background, so the file must be complete now
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to copy file: " + err.Error(),
@@ -68,8 +64,8 @@ func (h *PDFHandler) Upload(c *gin.Context) {
 		return
 	}
 
-	// Process PDF
-	resp, err := h.pdfUseCase.UploadPDF(filePath, filename)
+	// Queue PDF for processing; sessions of authenticated users are persisted
+	resp, err := h.pdfUseCase.UploadPDF(filePath, filename, c.GetString("userID"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to process PDF: " + err.Error(),
@@ -78,50 +74,20 @@ func (h *PDFHandler) Upload(c *gin.Context) {
 	}
 
 	// Convert Protobuf response to JSON
-	if resp.Status != proto.Status_STATUS_SUCCESS {
-		statusCode := http.StatusInternalServerError
-		if resp.Status == proto.Status_STATUS_NOT_FOUND {
-			statusCode = http.StatusNotFound
-		}
-		c.JSON(statusCode, gin.H{
+	if resp.Status != proto.Status_STATUS_PROCESSING {
+		c.JSON(errorStatusCode(resp.Status, resp.Error.Code), gin.H{
 			"error": resp.Error.Message,
 			"code":  resp.Error.Code,
 		})
 		return
 	}
 
-	// Persist to database if user is authenticated
-	if userID, exists := c.Get("userID"); exists {
-		now := time.Now()
-		if err := h.persistenceRepo.SaveSession(&repositories.DBSession{
-			ID:           resp.SessionId,
-			UserID:       userID.(string),
-			Title:        filename,
-			CreatedAt:    now,
-			LastActivity: now,
-		}); err != nil {
-			log.Printf("Failed to persist session: %v", err)
-		}
-		if err := h.persistenceRepo.SaveDocument(&repositories.DBDocument{
-			ID:          resp.Document.Id,
-			SessionID:   resp.SessionId,
-			Filename:    filename,
-			FilePath:    filePath,
-			Pages:       int(resp.Document.Pages),
-			ChunksCount: len(resp.Document.Chunks),
-			UploadedAt:  now,
-		}); err != nil {
-			log.Printf("Failed to persist document: %v", err)
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusAccepted, gin.H{
 		"document_id": resp.Document.Id,
 		"session_id":  resp.SessionId,
 		"filename":    resp.Document.Filename,
-		"pages":       resp.Document.Pages,
-		"chunks":      len(resp.Document.Chunks),
-		"message":     "PDF uploaded and processed successfully",
+		"status":      repositories.JobQueued,
+		"message":     "PDF uploaded and queued for processing",
 	})
 }
 
@@ -153,20 +119,29 @@ func (h *PDFHandler) Status(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	status := gin.H{
 		"id":       resp.Document.Id,
 		"filename": resp.Document.Filename,
 		"pages":    resp.Document.Pages,
 		"chunks":   len(resp.Document.Chunks),
-		"status":   "processed",
-	})
+		"status":   resp.Ingestion.State,
+		"progress": gin.H{
+			"pages_done":  resp.Ingestion.PagesDone,
+			"pages_total": resp.Ingestion.PagesTotal,
+		},
+	}
+	// Only failed documents carry an error
+	if resp.Ingestion.State == repositories.JobFailed {
+		status["error"] = resp.Ingestion.Error
+	}
+	c.JSON(http.StatusOK, status)
 }
 
 // ListSessionDocuments returns all documents in a session
 func (h *PDFHandler) ListSessionDocuments(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
-	docs, err := h.pdfUseCase.GetSessionDocuments(sessionID)
+	docs, err := h.pdfUseCase.GetSessionDocuments(c.Request.Context(), sessionID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
@@ -221,9 +196,9 @@ func (h *PDFHandler) AddToSession(c *gin.Context) {
 		})
 		return
 	}
-	defer out.Close()
 
 	_, err = io.Copy(out, file)
+	out.Close() // Processing happens in the background, so the file must be complete now
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to copy file: " + err.Error(),
@@ -231,8 +206,8 @@ func (h *PDFHandler) AddToSession(c *gin.Context) {
 		return
 	}
 
-	// Add PDF to existing session
-	resp, err := h.pdfUseCase.AddDocumentToSession(sessionID, filePath, filename)
+	// Add PDF to existing session and queue it for processing
+	resp, err := h.pdfUseCase.AddDocumentToSession(c.Request.Context(), sessionID, filePath, filename)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to process PDF: " + err.Error(),
@@ -240,37 +215,20 @@ func (h *PDFHandler) AddToSession(c *gin.Context) {
 		return
 	}
 
-	if resp.Status != proto.Status_STATUS_SUCCESS {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	if resp.Status != proto.Status_STATUS_PROCESSING {
+		c.JSON(errorStatusCode(resp.Status, resp.Error.Code), gin.H{
 			"error": resp.Error.Message,
 			"code":  resp.Error.Code,
 		})
 		return
 	}
 
-	// Persist to database if user is authenticated
-	if userID, exists := c.Get("userID"); exists {
-		_ = userID // session already saved, just save the document
-		if err := h.persistenceRepo.SaveDocument(&repositories.DBDocument{
-			ID:          resp.Document.Id,
-			SessionID:   sessionID,
-			Filename:    filename,
-			FilePath:    filePath,
-			Pages:       int(resp.Document.Pages),
-			ChunksCount: len(resp.Document.Chunks),
-			UploadedAt:  time.Now(),
-		}); err != nil {
-			log.Printf("Failed to persist document: %v", err)
-		}
-	}
-
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(http.StatusAccepted, gin.H{
 		"document_id": resp.Document.Id,
 		"session_id":  sessionID,
 		"filename":    resp.Document.Filename,
-		"pages":       resp.Document.Pages,
-		"chunks":      len(resp.Document.Chunks),
-		"message":     "PDF added to session successfully",
+		"status":      repositories.JobQueued,
+		"message":     "PDF added to session and queued for processing",
 	})
 }
 
@@ -286,7 +244,7 @@ func (h *PDFHandler) DeleteDocument(c *gin.Context) {
 		return
 	}
 
-	err := h.pdfUseCase.RemoveDocumentFromSession(sessionID, documentID)
+	err := h.pdfUseCase.RemoveDocumentFromSession(c.Request.Context(), sessionID, documentID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": err.Error(),
@@ -1,9 +1,9 @@
 package handlers
 
 import (
-	"net/http"
 	"ai-pdf-assistant-backend/proto"
 	"ai-pdf-assistant-backend/usecases"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,8 +23,11 @@ func NewSummaryHandler(summaryUseCase *usecases.SummaryUseCase) *SummaryHandler
 // Generate handles summary generation requests
 func (h *SummaryHandler) Generate(c *gin.Context) {
 	var jsonReq struct {
-		SessionID  string `json:"session_id" binding:"required"`
-		DocumentID string `json:"document_id"`
+		SessionID   string   `json:"session_id" binding:"required"`
+		DocumentID  string   `json:"document_id"`
+		DocumentIDs []string `json:"document_ids"`
+		Mode        string   `json:"mode"`
+		Refresh     bool     `json:"refresh"`
 	}
 
 	if err := c.ShouldBindJSON(&jsonReq); err != nil {
@@ -36,12 +39,15 @@ func (h *SummaryHandler) Generate(c *gin.Context) {
 
 	// Convert JSON to Protobuf
 	req := &proto.SummaryRequest{
-		SessionId:  jsonReq.SessionID,
-		DocumentId: jsonReq.DocumentID,
+		SessionId:   jsonReq.SessionID,
+		DocumentId:  jsonReq.DocumentID,
+		DocumentIds: jsonReq.DocumentIDs,
+		Mode:        jsonReq.Mode,
+		Refresh:     jsonReq.Refresh,
 	}
 
 	// Call use case
-	resp, err := h.summaryUseCase.GenerateSummary(req)
+	resp, err := h.summaryUseCase.GenerateSummary(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to generate summary: " + err.Error(),
@@ -51,11 +57,7 @@ func (h *SummaryHandler) Generate(c *gin.Context) {
 
 	// Convert Protobuf to JSON
 	if resp.Status != proto.Status_STATUS_SUCCESS {
-		statusCode := http.StatusInternalServerError
-		if resp.Status == proto.Status_STATUS_NOT_FOUND {
-			statusCode = http.StatusNotFound
-		}
-		c.JSON(statusCode, gin.H{
+		c.JSON(errorStatusCode(resp.Status, resp.Error.Code), gin.H{
 			"error": resp.Error.Message,
 			"code":  resp.Error.Code,
 		})
@@ -63,9 +65,15 @@ func (h *SummaryHandler) Generate(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"summary":       resp.Summary,
-		"key_takeaways": resp.KeyTakeaways,
-		"main_topics":   resp.MainTopics,
+		"summary":          resp.Summary,
+		"key_takeaways":    resp.KeyTakeaways,
+		"main_topics":      resp.MainTopics,
+		"mode":             resp.Mode,
+		"documents":        resp.Documents,
+		"takeaways":        resp.Takeaways,
+		"entities":         resp.Entities,
+		"page_references":  resp.PageReferences,
+		"coverage_percent": resp.CoveragePercent,
+		"cached":           resp.Cached,
 	})
 }
-
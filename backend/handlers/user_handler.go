@@ -4,18 +4,32 @@ import (
 	"net/http"
 
 	"ai-pdf-assistant-backend/infrastructure/repositories"
+	"ai-pdf-assistant-backend/usecases"
 
 	"github.com/gin-gonic/gin"
 )
 
 // UserHandler handles user-related requests (sessions, dashboard)
 type UserHandler struct {
-	persistenceRepo *repositories.PersistenceRepository
+	sessionStore   repositories.SessionStore
+	documentStore  repositories.DocumentStore
+	messageStore   repositories.MessageStore
+	sessionManager *usecases.SessionManager
 }
 
 // NewUserHandler creates a new user handler
-func NewUserHandler(persistenceRepo *repositories.PersistenceRepository) *UserHandler {
-	return &UserHandler{persistenceRepo: persistenceRepo}
+func NewUserHandler(
+	sessionStore repositories.SessionStore,
+	documentStore repositories.DocumentStore,
+	messageStore repositories.MessageStore,
+	sessionManager *usecases.SessionManager,
+) *UserHandler {
+	return &UserHandler{
+		sessionStore:   sessionStore,
+		documentStore:  documentStore,
+		messageStore:   messageStore,
+		sessionManager: sessionManager,
+	}
 }
 
 // GetSessions returns all sessions for the authenticated user
@@ -26,12 +40,20 @@ func (h *UserHandler) GetSessions(c *gin.Context) {
 		return
 	}
 
-	sessions, err := h.persistenceRepo.GetUserSessions(userID.(string))
+	sessions, err := h.sessionStore.ListByUser(userID.(string))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
 		return
 	}
 
+	// Get documents for each session
+	for i := range sessions {
+		docs, err := h.documentStore.ListBySession(sessions[i].ID)
+		if err == nil {
+			sessions[i].Documents = docs
+		}
+	}
+
 	if sessions == nil {
 		sessions = []repositories.DBSession{}
 	}
@@ -50,26 +72,11 @@ func (h *UserHandler) GetSessionMessages(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
 	// Verify session belongs to user
-	sessions, err := h.persistenceRepo.GetUserSessions(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
-		return
-	}
-
-	found := false
-	for _, s := range sessions {
-		if s.ID == sessionID {
-			found = true
-			break
-		}
-	}
-
-	if !found {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Session not found or access denied"})
+	if !h.ownsSession(c, userID.(string), sessionID) {
 		return
 	}
 
-	messages, err := h.persistenceRepo.GetSessionMessages(sessionID)
+	messages, err := h.messageStore.ListBySession(sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
 		return
@@ -93,29 +100,30 @@ func (h *UserHandler) DeleteSession(c *gin.Context) {
 	sessionID := c.Param("sessionId")
 
 	// Verify session belongs to user
-	sessions, err := h.persistenceRepo.GetUserSessions(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+	if !h.ownsSession(c, userID.(string), sessionID) {
 		return
 	}
 
-	found := false
-	for _, s := range sessions {
-		if s.ID == sessionID {
-			found = true
-			break
-		}
+	if err := h.sessionManager.Delete(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		return
 	}
 
-	if !found {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Session not found or access denied"})
-		return
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted"})
+}
+
+// ownsSession checks the session belongs to the user, writing an error response if not
+func (h *UserHandler) ownsSession(c *gin.Context, userID string, sessionID string) bool {
+	session, err := h.sessionStore.Get(sessionID)
+	if err != nil && err != repositories.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify session"})
+		return false
 	}
 
-	if err := h.persistenceRepo.DeleteSession(sessionID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
-		return
+	if session == nil || session.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Session not found or access denied"})
+		return false
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Session deleted"})
+	return true
 }
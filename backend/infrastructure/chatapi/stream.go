@@ -0,0 +1,69 @@
+// Package chatapi reads the replies of OpenAI-style chat completion APIs. It
+// has no dependencies within the module, so both the provider adapters and
+// the Groq client can share it.
+package chatapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// streamChunk is a single server-sent event of an OpenAI-style streamed completion
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ReadStream consumes an OpenAI-style `stream: true` response body,
+// forwarding each content delta to onToken and returning the full answer.
+func ReadStream(body io.Reader, onToken func(token string) error) (string, error) {
+	var answer strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // Skip blank lines, comments and other SSE fields
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return answer.String(), fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+
+		if chunk.Error != nil {
+			return answer.String(), fmt.Errorf("AI error: %s", chunk.Error.Message)
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content == "" {
+				continue
+			}
+			answer.WriteString(choice.Delta.Content)
+			if err := onToken(choice.Delta.Content); err != nil {
+				return answer.String(), err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return answer.String(), fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return answer.String(), nil
+}
@@ -0,0 +1,134 @@
+package repositories
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Ingestion job states
+const (
+	JobQueued     = "queued"
+	JobProcessing = "processing"
+	JobReady      = "ready"
+	JobFailed     = "failed"
+)
+
+// finishedJobTTL is how long a ready or failed job stays available to status
+// polls. Afterwards the stored document content answers them.
+const finishedJobTTL = 10 * time.Minute
+
+// IngestionJob tracks the background processing of an uploaded PDF
+type IngestionJob struct {
+	DocumentID string    `json:"document_id"`
+	SessionID  string    `json:"session_id"`
+	Filename   string    `json:"filename"`
+	FilePath   string    `json:"-"`
+	State      string    `json:"state"`
+	PagesDone  int       `json:"pages_done"`
+	PagesTotal int       `json:"pages_total"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// JobRepository handles ingestion job storage in memory
+type JobRepository struct {
+	jobs  map[string]*IngestionJob // document ID -> job
+	mutex sync.RWMutex
+}
+
+// NewJobRepository creates a new in-memory job repository
+func NewJobRepository() *JobRepository {
+	return &JobRepository{
+		jobs: make(map[string]*IngestionJob),
+	}
+}
+
+// Create registers a new queued job
+func (r *JobRepository) Create(job *IngestionJob) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+
+	job.State = JobQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	r.jobs[job.DocumentID] = job
+}
+
+// CreateIfMissing registers a new queued job unless the document already has
+// one. It reports whether the job was created.
+func (r *JobRepository) CreateIfMissing(job *IngestionJob) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	r.prune(now)
+	if _, exists := r.jobs[job.DocumentID]; exists {
+		return false
+	}
+
+	job.State = JobQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	r.jobs[job.DocumentID] = job
+	return true
+}
+
+// prune drops jobs that finished more than finishedJobTTL ago. The caller holds the lock.
+func (r *JobRepository) prune(now time.Time) {
+	for id, job := range r.jobs {
+		if (job.State == JobReady || job.State == JobFailed) && now.Sub(job.UpdatedAt) > finishedJobTTL {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// Get returns a copy of the job for a document
+func (r *JobRepository) Get(documentID string) (IngestionJob, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	job, exists := r.jobs[documentID]
+	if !exists {
+		return IngestionJob{}, fmt.Errorf("job not found: %s", documentID)
+	}
+
+	return *job, nil
+}
+
+// Active reports whether a document's job is still queued or processing
+func (r *JobRepository) Active(documentID string) bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	job, exists := r.jobs[documentID]
+	return exists && (job.State == JobQueued || job.State == JobProcessing)
+}
+
+// SetState moves a job to a new state, recording the failure reason if any
+func (r *JobRepository) SetState(documentID string, state string, reason string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if job, exists := r.jobs[documentID]; exists {
+		job.State = state
+		job.Error = reason
+		job.UpdatedAt = time.Now()
+	}
+}
+
+// SetProgress records how many pages have been processed
+func (r *JobRepository) SetProgress(documentID string, pagesDone int, pagesTotal int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if job, exists := r.jobs[documentID]; exists {
+		job.PagesDone = pagesDone
+		job.PagesTotal = pagesTotal
+		job.UpdatedAt = time.Now()
+	}
+}
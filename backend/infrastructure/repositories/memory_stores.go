@@ -0,0 +1,366 @@
+package repositories
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// MemorySessionStore implements SessionStore in memory
+type MemorySessionStore struct {
+	sessions map[string]DBSession
+	mutex    sync.RWMutex
+}
+
+// NewMemorySessionStore creates a new in-memory session store
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]DBSession),
+	}
+}
+
+// Create stores a new session
+func (s *MemorySessionStore) Create(session *DBSession) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.sessions[session.ID]; exists {
+		return fmt.Errorf("session already exists: %s", session.ID)
+	}
+
+	stored := *session
+	stored.Documents = nil
+	stored.Messages = nil
+	s.sessions[session.ID] = stored
+	return nil
+}
+
+// Get retrieves a session by ID
+func (s *MemorySessionStore) Get(id string) (*DBSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return &session, nil
+}
+
+// ListByUser returns all sessions for a user, most recently active first
+func (s *MemorySessionStore) ListByUser(userID string) ([]DBSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var sessions []DBSession
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+
+	return sessions, nil
+}
+
+// Touch records activity on a session
+func (s *MemorySessionStore) Touch(id string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return ErrNotFound
+	}
+
+	session.LastActivity = at
+	s.sessions[id] = session
+	return nil
+}
+
+// Delete removes a session
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// memoryDocument pairs a document record with its processed content
+type memoryDocument struct {
+	record  DBDocument
+	content *proto.Document // Nil until processed
+}
+
+// MemoryDocumentStore implements DocumentStore in memory
+type MemoryDocumentStore struct {
+	documents map[string]*memoryDocument
+	mutex     sync.RWMutex
+}
+
+// NewMemoryDocumentStore creates a new in-memory document store
+func NewMemoryDocumentStore() *MemoryDocumentStore {
+	return &MemoryDocumentStore{
+		documents: make(map[string]*memoryDocument),
+	}
+}
+
+// Create stores a new document record
+func (s *MemoryDocumentStore) Create(doc *DBDocument) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.documents[doc.ID]; exists {
+		return fmt.Errorf("document already exists: %s", doc.ID)
+	}
+
+	s.documents[doc.ID] = &memoryDocument{record: *doc}
+	return nil
+}
+
+// SaveContent records the text, page count and chunks of a processed document.
+// Documents removed while processing return ErrNotFound.
+func (s *MemoryDocumentStore) SaveContent(doc *proto.Document) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, exists := s.documents[doc.Id]
+	if !exists {
+		return ErrNotFound // Removed while processing
+	}
+
+	stored.record.Pages = int(doc.Pages)
+	stored.record.ChunksCount = len(doc.Chunks)
+	stored.content = doc
+	return nil
+}
+
+// Get retrieves a document record by ID
+func (s *MemoryDocumentStore) Get(id string) (*DBDocument, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stored, exists := s.documents[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	record := stored.record
+	return &record, nil
+}
+
+// GetContent returns a processed document with its chunks
+func (s *MemoryDocumentStore) GetContent(id string) (*proto.Document, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stored, exists := s.documents[id]
+	if !exists || stored.content == nil {
+		return nil, ErrNotFound
+	}
+
+	return stored.content, nil
+}
+
+// ListBySession returns all documents in a session, oldest first
+func (s *MemoryDocumentStore) ListBySession(sessionID string) ([]DBDocument, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var docs []DBDocument
+	for _, stored := range s.documents {
+		if stored.record.SessionID == sessionID {
+			docs = append(docs, stored.record)
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].UploadedAt.Before(docs[j].UploadedAt)
+	})
+
+	return docs, nil
+}
+
+// Delete removes a document
+func (s *MemoryDocumentStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.documents, id)
+	return nil
+}
+
+// MemoryMessageStore implements MessageStore in memory
+type MemoryMessageStore struct {
+	messages map[string][]DBMessage // session ID -> messages in order
+	mutex    sync.RWMutex
+}
+
+// NewMemoryMessageStore creates a new in-memory message store
+func NewMemoryMessageStore() *MemoryMessageStore {
+	return &MemoryMessageStore{
+		messages: make(map[string][]DBMessage),
+	}
+}
+
+// Create appends a message to its session's history
+func (s *MemoryMessageStore) Create(msg *DBMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.messages[msg.SessionID] = append(s.messages[msg.SessionID], *msg)
+	return nil
+}
+
+// ListBySession returns a session's messages, oldest first
+func (s *MemoryMessageStore) ListBySession(sessionID string) ([]DBMessage, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	messages := make([]DBMessage, len(s.messages[sessionID]))
+	copy(messages, s.messages[sessionID])
+	return messages, nil
+}
+
+// DeleteBySession removes a session's messages
+func (s *MemoryMessageStore) DeleteBySession(sessionID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.messages, sessionID)
+	return nil
+}
+
+// MemorySummaryStore implements SummaryStore in memory
+type MemorySummaryStore struct {
+	summaries map[string]StoredSummary // cache key -> summary
+	mutex     sync.RWMutex
+}
+
+// NewMemorySummaryStore creates a new in-memory summary store
+func NewMemorySummaryStore() *MemorySummaryStore {
+	return &MemorySummaryStore{
+		summaries: make(map[string]StoredSummary),
+	}
+}
+
+// Get retrieves a summary by cache key
+func (s *MemorySummaryStore) Get(key string) (*StoredSummary, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	summary, exists := s.summaries[key]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return &summary, nil
+}
+
+// Put stores a summary under its cache key
+func (s *MemorySummaryStore) Put(summary *StoredSummary) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.summaries[summary.Key] = *summary
+	return nil
+}
+
+// DeleteBySession removes a session's summaries
+func (s *MemorySummaryStore) DeleteBySession(sessionID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for key, summary := range s.summaries {
+		if summary.SessionID == sessionID {
+			delete(s.summaries, key)
+		}
+	}
+	return nil
+}
+
+// MemoryUserStore implements UserStore in memory
+type MemoryUserStore struct {
+	users map[string]*User // user ID -> user
+	mutex sync.RWMutex
+}
+
+// NewMemoryUserStore creates a new in-memory user store
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{
+		users: make(map[string]*User),
+	}
+}
+
+// Create creates a new user with hashed password
+func (s *MemoryUserStore) Create(email, password, name string) (*User, error) {
+	user, err := newUser(email, password, name)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.findByEmail(email) != nil {
+		return nil, fmt.Errorf("email already registered: %s", email)
+	}
+
+	s.users[user.ID] = user
+	stored := *user
+	return &stored, nil
+}
+
+// GetByEmail finds a user by email address
+func (s *MemoryUserStore) GetByEmail(email string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	user := s.findByEmail(email)
+	if user == nil {
+		return nil, ErrNotFound
+	}
+
+	found := *user
+	return &found, nil
+}
+
+// GetByID finds a user by ID
+func (s *MemoryUserStore) GetByID(id string) (*User, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	user, exists := s.users[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	found := *user
+	return &found, nil
+}
+
+// EmailExists checks if an email is already registered
+func (s *MemoryUserStore) EmailExists(email string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.findByEmail(email) != nil, nil
+}
+
+// findByEmail looks up a user by email; the caller must hold the mutex
+func (s *MemoryUserStore) findByEmail(email string) *User {
+	for _, user := range s.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"ai-pdf-assistant-backend/proto"
+
+	"github.com/google/uuid"
+)
+
+// SessionCache holds the sessions currently in use, with their document
+// chunks loaded. Stores remain the source of truth; evicted sessions are
+// rebuilt from them on demand.
+type SessionCache struct {
+	sessions map[string]*proto.ChatSession
+	mutex    sync.RWMutex
+}
+
+// NewSessionCache creates a new session cache
+func NewSessionCache() *SessionCache {
+	return &SessionCache{
+		sessions: make(map[string]*proto.ChatSession),
+	}
+}
+
+// Get retrieves a session by ID
+func (c *SessionCache) Get(id string) (*proto.ChatSession, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	session, exists := c.sessions[id]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", id)
+	}
+
+	return session, nil
+}
+
+// Put caches a session under its ID. If the session is already cached,
+// the cached copy is kept and returned.
+func (c *SessionCache) Put(session *proto.ChatSession) *proto.ChatSession {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if existing, exists := c.sessions[session.Id]; exists {
+		return existing
+	}
+
+	session.LastActivity = time.Now().Unix()
+	c.sessions[session.Id] = session
+	return session
+}
+
+// AddMessage adds a message to a session and returns a copy of the messages
+// that came before it
+func (c *SessionCache) AddMessage(sessionID string, message *proto.ChatMessage) ([]*proto.ChatMessage, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+	earlier := append([]*proto.ChatMessage(nil), session.Messages...)
+
+	if message.Id == "" {
+		message.Id = uuid.New().String()
+	}
+	if message.Timestamp == 0 {
+		message.Timestamp = time.Now().Unix()
+	}
+
+	session.Messages = append(session.Messages, message)
+	session.LastActivity = time.Now().Unix()
+
+	return earlier, nil
+}
+
+// ClearMessages clears all messages in a session
+func (c *SessionCache) ClearMessages(sessionID string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	session.Messages = []*proto.ChatMessage{}
+	session.LastActivity = time.Now().Unix()
+
+	return nil
+}
+
+// AddDocument adds a document to an existing session
+func (c *SessionCache) AddDocument(sessionID string, document *proto.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// Check if document already exists
+	for _, doc := range session.Documents {
+		if doc.Id == document.Id {
+			return fmt.Errorf("document already exists in session: %s", document.Id)
+		}
+	}
+
+	session.Documents = append(session.Documents, document)
+	session.LastActivity = time.Now().Unix()
+
+	return nil
+}
+
+// ReplaceDocument swaps in a new version of a document already in the session
+func (c *SessionCache) ReplaceDocument(sessionID string, document *proto.Document) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	if session.Document != nil && session.Document.Id == document.Id {
+		session.Document = document
+	}
+	for i, doc := range session.Documents {
+		if doc.Id == document.Id {
+			session.Documents[i] = document
+			return nil
+		}
+	}
+
+	return fmt.Errorf("document not found in session: %s", document.Id)
+}
+
+// GetDocuments returns all documents in a session
+func (c *SessionCache) GetDocuments(sessionID string) ([]*proto.Document, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return nil, fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	return session.Documents, nil
+}
+
+// RemoveDocument removes a document from a session
+func (c *SessionCache) RemoveDocument(sessionID string, documentID string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	session, exists := c.sessions[sessionID]
+	if !exists {
+		return fmt.Errorf("session not found: %s", sessionID)
+	}
+
+	// Find and remove document
+	for i, doc := range session.Documents {
+		if doc.Id == documentID {
+			session.Documents = append(session.Documents[:i], session.Documents[i+1:]...)
+			session.LastActivity = time.Now().Unix()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("document not found in session: %s", documentID)
+}
+
+// Delete evicts a session
+func (c *SessionCache) Delete(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.sessions, id)
+}
+
+// EvictInactive evicts sessions inactive for more than the specified duration
+// and returns their IDs
+func (c *SessionCache) EvictInactive(duration time.Duration) []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now().Unix()
+	threshold := now - int64(duration.Seconds())
+	var evicted []string
+
+	for id, session := range c.sessions {
+		if session.LastActivity < threshold {
+			delete(c.sessions, id)
+			evicted = append(evicted, id)
+		}
+	}
+
+	return evicted
+}
@@ -0,0 +1,458 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"ai-pdf-assistant-backend/database"
+	"ai-pdf-assistant-backend/proto"
+
+	"github.com/lib/pq"
+)
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// SQLSessionStore implements SessionStore on PostgreSQL or SQLite
+type SQLSessionStore struct {
+	db *sql.DB
+}
+
+// NewSQLSessionStore creates a new SQL session store
+func NewSQLSessionStore(db *sql.DB) *SQLSessionStore {
+	return &SQLSessionStore{db: db}
+}
+
+// Create stores a new session
+func (s *SQLSessionStore) Create(session *DBSession) error {
+	_, err := s.db.Exec(`
+		INSERT INTO sessions (id, user_id, title, created_at, last_activity)
+		VALUES ($1, $2, $3, $4, $5)
+	`, session.ID, nullString(session.UserID), session.Title, session.CreatedAt.UTC(), session.LastActivity.UTC())
+
+	return err
+}
+
+// Get retrieves a session by ID
+func (s *SQLSessionStore) Get(id string) (*DBSession, error) {
+	var session DBSession
+	err := s.db.QueryRow(`
+		SELECT id, COALESCE(CAST(user_id AS TEXT), ''), COALESCE(title, ''), created_at, last_activity
+		FROM sessions WHERE id = $1
+	`, id).Scan(&session.ID, &session.UserID, &session.Title, &session.CreatedAt, &session.LastActivity)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &session, nil
+}
+
+// ListByUser returns all sessions for a user, most recently active first
+func (s *SQLSessionStore) ListByUser(userID string) ([]DBSession, error) {
+	rows, err := s.db.Query(`
+		SELECT id, COALESCE(CAST(user_id AS TEXT), ''), COALESCE(title, ''), created_at, last_activity
+		FROM sessions
+		WHERE user_id = $1
+		ORDER BY last_activity DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []DBSession
+	for rows.Next() {
+		var session DBSession
+		if err := rows.Scan(&session.ID, &session.UserID, &session.Title, &session.CreatedAt, &session.LastActivity); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records activity on a session
+func (s *SQLSessionStore) Touch(id string, at time.Time) error {
+	_, err := s.db.Exec(`UPDATE sessions SET last_activity = $2 WHERE id = $1`, id, at.UTC())
+	return err
+}
+
+// Delete deletes a session; its documents and messages are removed by cascade
+func (s *SQLSessionStore) Delete(id string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = $1`, id)
+	return err
+}
+
+// SQLDocumentStore implements DocumentStore on PostgreSQL or SQLite
+type SQLDocumentStore struct {
+	db      *sql.DB
+	dialect database.Dialect // Decides how embeddings are encoded
+}
+
+// NewSQLDocumentStore creates a new SQL document store
+func NewSQLDocumentStore(db *sql.DB, dialect database.Dialect) *SQLDocumentStore {
+	return &SQLDocumentStore{db: db, dialect: dialect}
+}
+
+// Create stores a new document record
+func (s *SQLDocumentStore) Create(doc *DBDocument) error {
+	_, err := s.db.Exec(`
+		INSERT INTO documents (id, session_id, filename, file_path, pages, chunks_count, uploaded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, doc.ID, doc.SessionID, doc.Filename, doc.FilePath, doc.Pages, doc.ChunksCount, doc.UploadedAt.UTC())
+
+	return err
+}
+
+// SaveContent records the text, page count and chunks of a processed document.
+// Documents removed while processing return ErrNotFound.
+func (s *SQLDocumentStore) SaveContent(doc *proto.Document) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE documents SET text = $2, pages = $3, chunks_count = $4 WHERE id = $1
+	`, doc.Id, doc.Text, doc.Pages, len(doc.Chunks))
+	if err != nil {
+		return err
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrNotFound // Removed while processing
+	}
+
+	// Replace any chunks from an earlier run
+	if _, err := tx.Exec(`DELETE FROM document_chunks WHERE document_id = $1`, doc.Id); err != nil {
+		return err
+	}
+
+	for _, chunk := range doc.Chunks {
+		embedding, err := s.encodeEmbedding(chunk.Embedding)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			INSERT INTO document_chunks (id, document_id, chunk_index, text, start_page, end_page, start_offset, end_offset, embedding)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`, chunk.Id, doc.Id, chunk.ChunkIndex, chunk.Text, chunk.PageNumber, chunk.EndPageNumber, chunk.StartOffset, chunk.EndOffset, embedding); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Get retrieves a document record by ID
+func (s *SQLDocumentStore) Get(id string) (*DBDocument, error) {
+	var doc DBDocument
+	err := s.db.QueryRow(`
+		SELECT id, session_id, filename, COALESCE(file_path, ''), pages, chunks_count, uploaded_at
+		FROM documents WHERE id = $1
+	`, id).Scan(&doc.ID, &doc.SessionID, &doc.Filename, &doc.FilePath, &doc.Pages, &doc.ChunksCount, &doc.UploadedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &doc, nil
+}
+
+// GetContent returns a processed document with its chunks
+func (s *SQLDocumentStore) GetContent(id string) (*proto.Document, error) {
+	doc := &proto.Document{Id: id}
+	var text sql.NullString
+	var uploadedAt time.Time
+	err := s.db.QueryRow(`
+		SELECT filename, text, pages, uploaded_at FROM documents WHERE id = $1
+	`, id).Scan(&doc.Filename, &text, &doc.Pages, &uploadedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	doc.Text = text.String
+	doc.CreatedAt = uploadedAt.Unix()
+
+	rows, err := s.db.Query(`
+		SELECT id, chunk_index, text, start_page, end_page, start_offset, end_offset, embedding
+		FROM document_chunks WHERE document_id = $1
+		ORDER BY chunk_index ASC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chunk proto.Chunk
+		var embedding sql.NullString
+		if err := rows.Scan(&chunk.Id, &chunk.ChunkIndex, &chunk.Text, &chunk.PageNumber, &chunk.EndPageNumber, &chunk.StartOffset, &chunk.EndOffset, &embedding); err != nil {
+			return nil, err
+		}
+		if chunk.Embedding, err = s.decodeEmbedding(embedding); err != nil {
+			return nil, err
+		}
+		doc.Chunks = append(doc.Chunks, &chunk)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Chunks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return doc, nil
+}
+
+// ListBySession returns all documents in a session, oldest first
+func (s *SQLDocumentStore) ListBySession(sessionID string) ([]DBDocument, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_id, filename, COALESCE(file_path, ''), pages, chunks_count, uploaded_at
+		FROM documents WHERE session_id = $1
+		ORDER BY uploaded_at ASC
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []DBDocument
+	for rows.Next() {
+		var d DBDocument
+		if err := rows.Scan(&d.ID, &d.SessionID, &d.Filename, &d.FilePath, &d.Pages, &d.ChunksCount, &d.UploadedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+
+	return docs, rows.Err()
+}
+
+// Delete deletes a document; its chunks are removed by cascade
+func (s *SQLDocumentStore) Delete(id string) error {
+	_, err := s.db.Exec(`DELETE FROM documents WHERE id = $1`, id)
+	return err
+}
+
+// encodeEmbedding converts a vector to a REAL[] for PostgreSQL or a JSON array for SQLite
+func (s *SQLDocumentStore) encodeEmbedding(embedding []float32) (interface{}, error) {
+	if len(embedding) == 0 {
+		return nil, nil
+	}
+
+	if s.dialect == database.SQLite {
+		encoded, err := json.Marshal(embedding)
+		if err != nil {
+			return nil, err
+		}
+		return string(encoded), nil
+	}
+	return pq.Float32Array(embedding), nil
+}
+
+// decodeEmbedding reverses encodeEmbedding
+func (s *SQLDocumentStore) decodeEmbedding(value sql.NullString) ([]float32, error) {
+	if !value.Valid || value.String == "" {
+		return nil, nil
+	}
+
+	if s.dialect == database.SQLite {
+		var embedding []float32
+		err := json.Unmarshal([]byte(value.String), &embedding)
+		return embedding, err
+	}
+
+	var embedding pq.Float32Array
+	err := embedding.Scan([]byte(value.String))
+	return embedding, err
+}
+
+// SQLMessageStore implements MessageStore on PostgreSQL or SQLite
+type SQLMessageStore struct {
+	db *sql.DB
+}
+
+// NewSQLMessageStore creates a new SQL message store
+func NewSQLMessageStore(db *sql.DB) *SQLMessageStore {
+	return &SQLMessageStore{db: db}
+}
+
+// Create saves a chat message
+func (s *SQLMessageStore) Create(msg *DBMessage) error {
+	// Bind as text so SQLite's json_valid check sees a string, not a blob
+	var citations interface{}
+	if len(msg.Citations) > 0 {
+		citations = string(msg.Citations)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO chat_messages (id, session_id, role, content, citations, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, msg.ID, msg.SessionID, msg.Role, msg.Content, citations, msg.CreatedAt.UTC())
+
+	return err
+}
+
+// ListBySession returns a session's messages, oldest first
+func (s *SQLMessageStore) ListBySession(sessionID string) ([]DBMessage, error) {
+	rows, err := s.db.Query(`
+		SELECT id, session_id, role, content, citations, created_at
+		FROM chat_messages WHERE session_id = $1
+		ORDER BY created_at ASC
+	`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []DBMessage
+	for rows.Next() {
+		var m DBMessage
+		var citations sql.NullString
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Role, &m.Content, &citations, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if citations.Valid {
+			m.Citations = []byte(citations.String)
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// DeleteBySession removes a session's messages
+func (s *SQLMessageStore) DeleteBySession(sessionID string) error {
+	_, err := s.db.Exec(`DELETE FROM chat_messages WHERE session_id = $1`, sessionID)
+	return err
+}
+
+// SQLSummaryStore implements SummaryStore on PostgreSQL or SQLite
+type SQLSummaryStore struct {
+	db *sql.DB
+}
+
+// NewSQLSummaryStore creates a new SQL summary store
+func NewSQLSummaryStore(db *sql.DB) *SQLSummaryStore {
+	return &SQLSummaryStore{db: db}
+}
+
+// Get retrieves a summary by cache key
+func (s *SQLSummaryStore) Get(key string) (*StoredSummary, error) {
+	var summary StoredSummary
+	var content string
+	err := s.db.QueryRow(`
+		SELECT cache_key, session_id, provider, model, prompt_version, content, created_at
+		FROM summaries WHERE cache_key = $1
+	`, key).Scan(&summary.Key, &summary.SessionID, &summary.Provider, &summary.Model,
+		&summary.PromptVersion, &content, &summary.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	summary.Content = []byte(content)
+	return &summary, nil
+}
+
+// Put stores a summary, replacing any stored under the same key
+func (s *SQLSummaryStore) Put(summary *StoredSummary) error {
+	// Bind content as text so SQLite's json_valid check sees a string, not a blob
+	_, err := s.db.Exec(`
+		INSERT INTO summaries (cache_key, session_id, provider, model, prompt_version, content, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (cache_key) DO UPDATE SET content = excluded.content, created_at = excluded.created_at
+	`, summary.Key, summary.SessionID, summary.Provider, summary.Model, summary.PromptVersion,
+		string(summary.Content), summary.CreatedAt.UTC())
+
+	return err
+}
+
+// DeleteBySession removes a session's summaries
+func (s *SQLSummaryStore) DeleteBySession(sessionID string) error {
+	_, err := s.db.Exec(`DELETE FROM summaries WHERE session_id = $1`, sessionID)
+	return err
+}
+
+// SQLUserStore implements UserStore on PostgreSQL or SQLite
+type SQLUserStore struct {
+	db *sql.DB
+}
+
+// NewSQLUserStore creates a new SQL user store
+func NewSQLUserStore(db *sql.DB) *SQLUserStore {
+	return &SQLUserStore{db: db}
+}
+
+// Create creates a new user with hashed password
+func (s *SQLUserStore) Create(email, password, name string) (*User, error) {
+	user, err := newUser(email, password, name)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO users (id, email, password_hash, name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, user.ID, user.Email, user.PasswordHash, user.Name, user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// GetByEmail finds a user by email address
+func (s *SQLUserStore) GetByEmail(email string) (*User, error) {
+	return s.scanUser(s.db.QueryRow(`
+		SELECT id, email, password_hash, COALESCE(name, ''), created_at, updated_at
+		FROM users WHERE email = $1
+	`, email))
+}
+
+// GetByID finds a user by ID
+func (s *SQLUserStore) GetByID(id string) (*User, error) {
+	return s.scanUser(s.db.QueryRow(`
+		SELECT id, email, password_hash, COALESCE(name, ''), created_at, updated_at
+		FROM users WHERE id = $1
+	`, id))
+}
+
+// EmailExists checks if an email is already registered
+func (s *SQLUserStore) EmailExists(email string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM users WHERE email = $1`, email).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// scanUser reads a single user row
+func (s *SQLUserStore) scanUser(row *sql.Row) (*User, error) {
+	user := &User{}
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"ai-pdf-assistant-backend/proto"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrNotFound is returned by stores when a record does not exist
+var ErrNotFound = errors.New("not found")
+
+// SessionStore keeps chat sessions and who owns them
+type SessionStore interface {
+	Create(session *DBSession) error
+	Get(id string) (*DBSession, error)
+	ListByUser(userID string) ([]DBSession, error)
+	Touch(id string, at time.Time) error
+	Delete(id string) error
+}
+
+// DocumentStore keeps uploaded documents along with their extracted text and chunks
+type DocumentStore interface {
+	Create(doc *DBDocument) error
+	// SaveContent records the text, page count and chunks of a processed document,
+	// or returns ErrNotFound if the document was removed meanwhile
+	SaveContent(doc *proto.Document) error
+	Get(id string) (*DBDocument, error)
+	// GetContent returns a processed document with its chunks, or ErrNotFound
+	// if the document has not been processed
+	GetContent(id string) (*proto.Document, error)
+	ListBySession(sessionID string) ([]DBDocument, error)
+	Delete(id string) error
+}
+
+// MessageStore keeps chat history
+type MessageStore interface {
+	Create(msg *DBMessage) error
+	ListBySession(sessionID string) ([]DBMessage, error)
+	DeleteBySession(sessionID string) error
+}
+
+// UserStore keeps registered users
+type UserStore interface {
+	Create(email, password, name string) (*User, error)
+	GetByEmail(email string) (*User, error)
+	GetByID(id string) (*User, error)
+	EmailExists(email string) (bool, error)
+}
+
+// SummaryStore caches generated summaries
+type SummaryStore interface {
+	// Get returns the summary stored under key, or ErrNotFound
+	Get(key string) (*StoredSummary, error)
+	// Put stores a summary, replacing any stored under the same key
+	Put(summary *StoredSummary) error
+	DeleteBySession(sessionID string) error
+}
+
+// DBSession represents a stored session
+type DBSession struct {
+	ID           string       `json:"id"`
+	UserID       string       `json:"user_id"` // Empty for anonymous sessions
+	Title        string       `json:"title"`
+	CreatedAt    time.Time    `json:"created_at"`
+	LastActivity time.Time    `json:"last_activity"`
+	Documents    []DBDocument `json:"documents,omitempty"`
+	Messages     []DBMessage  `json:"messages,omitempty"`
+}
+
+// DBDocument represents a stored document
+type DBDocument struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Filename    string    `json:"filename"`
+	FilePath    string    `json:"file_path,omitempty"`
+	Pages       int       `json:"pages"`
+	ChunksCount int       `json:"chunks_count"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// DBMessage represents a stored chat message
+type DBMessage struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"session_id"`
+	Role      string          `json:"role"`
+	Content   string          `json:"content"`
+	Citations json.RawMessage `json:"citations,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// StoredSummary represents a cached summary response
+type StoredSummary struct {
+	Key           string          `json:"key"`
+	SessionID     string          `json:"session_id"`
+	Provider      string          `json:"provider"`
+	Model         string          `json:"model"`
+	PromptVersion string          `json:"prompt_version"`
+	Content       json.RawMessage `json:"content"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// User represents a registered user
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Name         string    `json:"name,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// VerifyPassword checks if the provided password matches the user's hashed password
+func VerifyPassword(user *User, password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	return err == nil
+}
+
+// newUser builds a user record with a hashed password
+func newUser(email, password, name string) (*User, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &User{
+		ID:           uuid.New().String(),
+		Email:        email,
+		PasswordHash: string(hashedPassword),
+		Name:         name,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}, nil
+}
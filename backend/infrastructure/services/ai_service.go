@@ -2,6 +2,7 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,12 +10,46 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
 )
 
 // AIService interface for AI providers
 type AIService interface {
-	AnswerQuestion(context string, question string, history []string) (string, bool, error)
-	GenerateSummary(text string) (string, []string, []string, error)
+	AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error)
+	// StreamAnswer answers like AnswerQuestion but passes each token to onToken as it
+	// arrives. It returns the complete answer once the stream ends.
+	StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error)
+	GenerateSummary(ctx context.Context, text string) (*SummaryResult, error)
+	// CheckGrounding judges whether the document context supports an answer
+	CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error)
+	// RewriteQuery turns a follow-up question into a standalone search query
+	// using the conversation so far
+	RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error)
+	// SummarizeHistory extends the summary of a conversation, empty at first,
+	// with later messages
+	SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error)
+	// ModelInfo names the provider and model that answer requests
+	ModelInfo() ModelInfo
+}
+
+// ChatMessage is an earlier message of the conversation, sent with its role
+type ChatMessage struct {
+	Role    string `json:"role"` // "user" or "assistant", or "system" for a summary of older turns
+	Content string `json:"content"`
+}
+
+// ModelInfo identifies an AI provider and model
+type ModelInfo struct {
+	Provider      string `json:"provider"`
+	Model         string `json:"model"`
+	ContextWindow int    `json:"context_window"` // Tokens the model accepts, prompt and answer together
+}
+
+// Is reports whether info names the same provider and model
+func (info ModelInfo) Is(other ModelInfo) bool {
+	return info.Provider == other.Provider && info.Model == other.Model
 }
 
 // PuterAIService implements AIService using Puter AI
@@ -40,9 +75,15 @@ func NewPuterAIService() *PuterAIService {
 
 // PuterAIRequest represents a request to Puter AI
 type PuterAIRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Stream         bool            `json:"stream"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat requests a reply format, e.g. {"type": "json_object"}
+type ResponseFormat struct {
+	Type string `json:"type"`
 }
 
 // Message represents a chat message
@@ -63,36 +104,29 @@ type PuterAIResponse struct {
 	} `json:"error"`
 }
 
-// AnswerQuestion answers a question based on context
-func (s *PuterAIService) AnswerQuestion(context string, question string, history []string) (string, bool, error) {
-	// Build prompt
-	prompt := buildQuestionPrompt(context, question, history)
+// ModelInfo implements AIService
+func (s *PuterAIService) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: "puter", Model: "gpt-3.5-turbo", ContextWindow: 16385}
+}
 
+// AnswerQuestion answers a question based on context
+func (s *PuterAIService) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
 	// Prepare request
 	reqBody := PuterAIRequest{
-		Model: "gpt-3.5-turbo", // Default model
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a helpful assistant that answers questions based ONLY on the provided document context. If the answer is not in the context, say 'I cannot find this information in the document.'",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: false,
+		Model:    "gpt-3.5-turbo", // Default model
+		Messages: questionMessages(docContext, question, history),
+		Stream:   false,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// Make HTTP request
-	req, err := http.NewRequest("POST", s.baseURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", false, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -104,66 +138,130 @@ func (s *PuterAIService) AnswerQuestion(context string, question string, history
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to make request: %w", err)
+		return "", fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", false, fmt.Errorf("failed to read response: %w", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", false, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+		return "", chatapi.NewAPIError(resp, body)
 	}
 
 	var aiResp PuterAIResponse
 	if err := json.Unmarshal(body, &aiResp); err != nil {
-		return "", false, fmt.Errorf("failed to parse response: %w", err)
+		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if aiResp.Error != nil {
-		return "", false, fmt.Errorf("AI error: %s", aiResp.Error.Message)
+		return "", fmt.Errorf("AI error: %s", aiResp.Error.Message)
 	}
 
 	if len(aiResp.Choices) == 0 {
-		return "", false, fmt.Errorf("no response from AI")
+		return "", fmt.Errorf("no response from AI")
 	}
 
 	answer := aiResp.Choices[0].Message.Content
-	answerFound := !strings.Contains(strings.ToLower(answer), "cannot find") &&
-		!strings.Contains(strings.ToLower(answer), "not in the document")
+	return answer, nil
+}
+
+// StreamAnswer answers a question based on context, streaming tokens as they arrive
+func (s *PuterAIService) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	reqBody := PuterAIRequest{
+		Model:    "gpt-3.5-turbo", // Default model
+		Messages: questionMessages(docContext, question, history),
+		Stream:   true,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	apiKey := os.Getenv("PUTER_AI_KEY")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
 
-	return answer, answerFound, nil
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", chatapi.NewAPIError(resp, body)
+	}
+
+	answer, err := chatapi.ReadStream(resp.Body, onToken)
+	if err != nil {
+		return "", err
+	}
+
+	if answer == "" {
+		return "", fmt.Errorf("no response from AI")
+	}
+
+	return answer, nil
 }
 
-// GenerateSummary generates a summary of the text
-func (s *PuterAIService) GenerateSummary(text string) (string, []string, []string, error) {
-	prompt := buildSummaryPrompt(text)
+// CheckGrounding asks the model whether the document context supports the answer
+func (s *PuterAIService) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	return checkGrounding(ctx, docContext, question, answer, s.completeJSON)
+}
+
+// GenerateSummary generates a structured summary of the text
+func (s *PuterAIService) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
+	return generateStructuredSummary(ctx, text, s.ModelInfo().ContextWindow, s.completeJSON)
+}
 
+// RewriteQuery turns a follow-up question into a standalone search query
+func (s *PuterAIService) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	return rewriteQuery(ctx, question, history, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, nil)
+	})
+}
+
+// SummarizeHistory extends the summary of a conversation with later messages
+func (s *PuterAIService) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	return summarizeHistory(ctx, previous, messages, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, nil)
+	})
+}
+
+// completeJSON sends a non-streamed request asking for a JSON reply
+func (s *PuterAIService) completeJSON(ctx context.Context, messages []Message) (string, error) {
+	return s.complete(ctx, messages, &ResponseFormat{Type: "json_object"})
+}
+
+// complete sends a non-streamed request, with an optional response format
+func (s *PuterAIService) complete(ctx context.Context, messages []Message, format *ResponseFormat) (string, error) {
 	reqBody := PuterAIRequest{
-		Model: "gpt-3.5-turbo",
-		Messages: []Message{
-			{
-				Role:    "system",
-				Content: "You are a helpful assistant that creates concise summaries in bullet point format.",
-			},
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Stream: false,
+		Model:          "gpt-3.5-turbo",
+		Messages:       messages,
+		Stream:         false,
+		ResponseFormat: format,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to marshal request: %w", err)
+		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", s.baseURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", s.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -174,96 +272,63 @@ func (s *PuterAIService) GenerateSummary(text string) (string, []string, []strin
 
 	resp, err := s.client.Do(req)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to make request: %w", err)
+		return "", fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", nil, nil, fmt.Errorf("failed to read response: %w", err)
+		return "", fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", nil, nil, fmt.Errorf("API error: %s - %s", resp.Status, string(body))
+		return "", chatapi.NewAPIError(resp, body)
 	}
 
 	var aiResp PuterAIResponse
 	if err := json.Unmarshal(body, &aiResp); err != nil {
-		return "", nil, nil, fmt.Errorf("failed to parse response: %w", err)
+		return "", fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if aiResp.Error != nil {
-		return "", nil, nil, fmt.Errorf("AI error: %s", aiResp.Error.Message)
+		return "", fmt.Errorf("AI error: %s", aiResp.Error.Message)
 	}
 
 	if len(aiResp.Choices) == 0 {
-		return "", nil, nil, fmt.Errorf("no response from AI")
+		return "", fmt.Errorf("no response from AI")
 	}
 
-	summary := aiResp.Choices[0].Message.Content
-	// Parse summary into takeaways and topics (simplified for MVP)
-	takeaways := extractTakeaways(summary)
-	topics := extractTopics(summary)
-
-	return summary, takeaways, topics, nil
+	return aiResp.Choices[0].Message.Content, nil
 }
 
 // buildQuestionPrompt builds the prompt for question answering
-func buildQuestionPrompt(context string, question string, history []string) string {
+func buildQuestionPrompt(docContext string, question string) string {
 	var builder strings.Builder
 
-	builder.WriteString(context)
+	builder.WriteString(docContext)
 	builder.WriteString("\n\n")
 
-	if len(history) > 0 {
-		builder.WriteString("Previous conversation:\n")
-		for i, msg := range history {
-			if i >= len(history)-4 { // Last 4 messages
-				builder.WriteString("- ")
-				builder.WriteString(msg)
-				builder.WriteString("\n")
-			}
-		}
-		builder.WriteString("\n")
-	}
-
 	builder.WriteString("Question: ")
 	builder.WriteString(question)
 	builder.WriteString("\n\n")
-	builder.WriteString("Answer the question based ONLY on the document context above. If the answer is not in the context, respond with: 'I cannot find this information in the document.'")
+	builder.WriteString("Answer the question based ONLY on the document context above. If the answer is not in the context, respond with: '" + noAnswerReply + "' ")
+	builder.WriteString(citationInstruction)
 
 	return builder.String()
 }
 
-// buildSummaryPrompt builds the prompt for summary generation
-func buildSummaryPrompt(text string) string {
-	// Limit text length for prompt
-	maxLength := 8000
-	if len(text) > maxLength {
-		text = text[:maxLength] + "..."
+// buildSummaryPrompt builds the prompt for summary generation, cutting the
+// text to maxTokens
+func buildSummaryPrompt(text string, maxTokens int) string {
+	if truncated, cut := tokenizer.Truncate(text, maxTokens); cut {
+		text = truncated + "\n... [truncated]"
 	}
 
-	return fmt.Sprintf(`Please provide a comprehensive summary of the following document in bullet point format.
-
-Include:
-1. Main topics and themes
-2. Key takeaways
-3. Important details
-
-Document:
-%s
-
-Format your response as:
-- Summary: [brief overview]
-- Key Takeaways:
-  • [takeaway 1]
-  • [takeaway 2]
-- Main Topics:
-  • [topic 1]
-  • [topic 2]`, text)
+	return fmt.Sprintf("Summarize the following document as a JSON object in the required schema.\n\nDocument:\n%s", text)
 }
 
-// extractTakeaways extracts key takeaways from summary (simplified)
+// extractTakeaways extracts key takeaways from a free-text summary. Only used
+// when a provider fails to return structured output.
 func extractTakeaways(summary string) []string {
 	lines := strings.Split(summary, "\n")
 	takeaways := []string{}
@@ -293,7 +358,8 @@ func extractTakeaways(summary string) []string {
 	return takeaways
 }
 
-// extractTopics extracts main topics from summary (simplified)
+// extractTopics extracts main topics from a free-text summary. Only used when
+// a provider fails to return structured output.
 func extractTopics(summary string) []string {
 	// Simple extraction - look for topic markers
 	topics := []string{}
@@ -326,4 +392,3 @@ func extractTopics(summary string) []string {
 
 	return topics
 }
-
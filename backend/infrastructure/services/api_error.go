@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+)
+
+// isTransient reports whether a failed call may succeed if retried: rate limits,
+// server errors, timeouts and connection failures. The caller's own cancellation
+// is never transient.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiErr *chatapi.APIError
+	if errors.As(err, &apiErr) {
+		status := apiErr.StatusCode
+		return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// retryAfter returns the delay a provider asked for before the next attempt, if any
+func retryAfter(err error) time.Duration {
+	var apiErr *chatapi.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+	return 0
+}
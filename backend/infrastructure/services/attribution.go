@@ -0,0 +1,122 @@
+package services
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// citationInstruction asks providers to mark the numbered chunks each sentence relies on
+const citationInstruction = "After each sentence, cite the numbered chunks that support it with markers such as [1] or [2][3]."
+
+// citationMarker matches markers such as [2] or [1, 3]
+var citationMarker = regexp.MustCompile(`\[(\d+(?:\s*,\s*\d+)*)\]`)
+
+// AttributeAnswer splits an answer into sentences and reads the [n] markers of
+// each. Markers refer to chunks as numbered by BuildContext; numbers outside
+// 1..chunkCount are ignored. Sentences without a valid marker are unsupported.
+func AttributeAnswer(answer string, chunkCount int) []*proto.AnswerSentence {
+	var sentences []*proto.AnswerSentence
+
+	for _, span := range sentenceSpans(answer) {
+		text := answer[span[0]:span[1]]
+
+		var markers []int32
+		seen := make(map[int32]bool)
+		for _, match := range citationMarker.FindAllStringSubmatch(text, -1) {
+			for _, number := range strings.Split(match[1], ",") {
+				n, err := strconv.Atoi(strings.TrimSpace(number))
+				if err != nil || n < 1 || n > chunkCount || seen[int32(n)] {
+					continue
+				}
+				seen[int32(n)] = true
+				markers = append(markers, int32(n))
+			}
+		}
+
+		// Skip fragments that are only markers or punctuation, e.g. a list bullet
+		if !strings.ContainsFunc(citationMarker.ReplaceAllString(text, ""), func(r rune) bool {
+			return unicode.IsLetter(r) || unicode.IsDigit(r)
+		}) {
+			continue
+		}
+
+		sentences = append(sentences, &proto.AnswerSentence{
+			Text:      text,
+			Start:     int32(utf8.RuneCountInString(answer[:span[0]])),
+			End:       int32(utf8.RuneCountInString(answer[:span[1]])),
+			Citations: markers,
+			Supported: len(markers) > 0,
+		})
+	}
+
+	return sentences
+}
+
+// CitedSources keeps the citations whose chunks the sentences cite, linking
+// each to the sentences it supports
+func CitedSources(citations []*proto.Citation, sentences []*proto.AnswerSentence) []*proto.Citation {
+	supports := make(map[int32][]int32)
+	for i, sentence := range sentences {
+		for _, marker := range sentence.Citations {
+			supports[marker] = append(supports[marker], int32(i))
+		}
+	}
+
+	cited := make([]*proto.Citation, 0, len(supports))
+	for _, citation := range citations {
+		if indices, ok := supports[citation.Marker]; ok {
+			citation.Sentences = indices
+			cited = append(cited, citation)
+		}
+	}
+	return cited
+}
+
+// sentenceSpans returns the byte ranges of the sentences of text, without
+// surrounding whitespace. A sentence ends at a line break, or at ., ! or ?
+// followed by whitespace; markers right after the punctuation stay with it.
+func sentenceSpans(text string) [][2]int {
+	var spans [][2]int
+	start := 0
+
+	add := func(end int) {
+		segment := text[start:end]
+		trimmed := strings.TrimLeftFunc(segment, unicode.IsSpace)
+		from := start + len(segment) - len(trimmed)
+		to := from + len(strings.TrimRightFunc(trimmed, unicode.IsSpace))
+		if to > from {
+			spans = append(spans, [2]int{from, to})
+		}
+		start = end
+	}
+
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\n':
+			add(i + 1)
+		case '.', '!', '?':
+			end := i + 1
+			// Keep markers placed after the punctuation, as in "... in 2027.[2]"
+			for {
+				rest := strings.TrimLeft(text[end:], " ")
+				loc := citationMarker.FindStringIndex(rest)
+				if loc == nil || loc[0] != 0 {
+					break
+				}
+				end += len(text[end:]) - len(rest) + loc[1]
+			}
+			if end == len(text) || text[end] == ' ' || text[end] == '\n' || text[end] == '\t' {
+				add(end)
+				i = end - 1
+			}
+		}
+	}
+	add(len(text))
+
+	return spans
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// BM25 tuning parameters (standard defaults)
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// stopwords are common English words ignored during indexing and querying
+var stopwords = map[string]bool{
+	"a": true, "about": true, "above": true, "after": true, "again": true, "against": true,
+	"all": true, "am": true, "an": true, "and": true, "any": true, "are": true, "as": true,
+	"at": true, "be": true, "because": true, "been": true, "before": true, "being": true,
+	"below": true, "between": true, "both": true, "but": true, "by": true, "can": true,
+	"could": true, "did": true, "do": true, "does": true, "doing": true, "down": true,
+	"during": true, "each": true, "few": true, "for": true, "from": true, "further": true,
+	"had": true, "has": true, "have": true, "having": true, "he": true, "her": true,
+	"here": true, "hers": true, "herself": true, "him": true, "himself": true, "his": true,
+	"how": true, "i": true, "if": true, "in": true, "into": true, "is": true, "it": true,
+	"its": true, "itself": true, "just": true, "me": true, "more": true, "most": true,
+	"my": true, "myself": true, "no": true, "nor": true, "not": true, "now": true, "of": true,
+	"off": true, "on": true, "once": true, "only": true, "or": true, "other": true,
+	"our": true, "ours": true, "ourselves": true, "out": true, "over": true, "own": true,
+	"same": true, "she": true, "should": true, "so": true, "some": true, "such": true,
+	"than": true, "that": true, "the": true, "their": true, "theirs": true, "them": true,
+	"themselves": true, "then": true, "there": true, "these": true, "they": true,
+	"this": true, "those": true, "through": true, "to": true, "too": true, "under": true,
+	"until": true, "up": true, "very": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "while": true, "who": true,
+	"whom": true, "why": true, "will": true, "with": true, "would": true, "you": true,
+	"your": true, "yours": true, "yourself": true, "yourselves": true,
+}
+
+// Tokenizer turns text into normalized search terms
+type Tokenizer struct {
+	stemming bool
+}
+
+// NewTokenizer creates a tokenizer, optionally reducing words to their stems
+func NewTokenizer(stemming bool) *Tokenizer {
+	return &Tokenizer{stemming: stemming}
+}
+
+// Tokenize lowercases text, splits it on non-alphanumeric characters and
+// drops stopwords. Whole words are kept, so "cat" never matches "education".
+func (t *Tokenizer) Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	tokens := make([]string, 0, len(words))
+	for _, word := range words {
+		if stopwords[word] || (len(word) < 2 && !unicode.IsDigit(rune(word[0]))) {
+			continue
+		}
+		if t.stemming {
+			word = stem(word)
+		}
+		tokens = append(tokens, word)
+	}
+
+	return tokens
+}
+
+// stem applies light English suffix stripping so that simple inflections
+// ("policies", "policy") share a term. Short words are left untouched.
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "ies") && len(word) > 4:
+		return word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "sses"):
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "ing") && len(word) > 5:
+		return undouble(word[:len(word)-3])
+	case strings.HasSuffix(word, "ed") && len(word) > 4:
+		return undouble(word[:len(word)-2])
+	case strings.HasSuffix(word, "ly") && len(word) > 5:
+		return word[:len(word)-2]
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us"):
+		return word[:len(word)-1]
+	}
+
+	return word
+}
+
+// undouble trims a doubled final consonant left behind by suffix removal ("runn" -> "run")
+func undouble(word string) string {
+	n := len(word)
+	if n >= 3 && word[n-1] == word[n-2] && !strings.ContainsRune("aeiouls", rune(word[n-1])) {
+		return word[:n-1]
+	}
+	return word
+}
+
+// posting records how often a term occurs in a chunk
+type posting struct {
+	chunkID   string
+	frequency int
+}
+
+// BM25Index is an inverted index over the chunks of a single document
+type BM25Index struct {
+	postings    map[string][]posting
+	chunkLength map[string]int
+	avgLength   float64
+	tokenizer   *Tokenizer
+}
+
+// NewBM25Index builds an inverted index over the given chunks
+func NewBM25Index(chunks []*proto.Chunk, tokenizer *Tokenizer) *BM25Index {
+	idx := &BM25Index{
+		postings:    make(map[string][]posting),
+		chunkLength: make(map[string]int, len(chunks)),
+		tokenizer:   tokenizer,
+	}
+
+	totalLength := 0
+	for _, chunk := range chunks {
+		terms := tokenizer.Tokenize(chunk.Text)
+		idx.chunkLength[chunk.Id] = len(terms)
+		totalLength += len(terms)
+
+		frequencies := make(map[string]int)
+		for _, term := range terms {
+			frequencies[term]++
+		}
+		for term, freq := range frequencies {
+			idx.postings[term] = append(idx.postings[term], posting{chunkID: chunk.Id, frequency: freq})
+		}
+	}
+
+	if len(chunks) > 0 {
+		idx.avgLength = float64(totalLength) / float64(len(chunks))
+	}
+
+	return idx
+}
+
+// Score returns the BM25 score of every chunk matching at least one query term
+func (idx *BM25Index) Score(query string) map[string]float64 {
+	scores := make(map[string]float64)
+	n := float64(len(idx.chunkLength))
+	if n == 0 || idx.avgLength == 0 {
+		return scores
+	}
+
+	// Count each distinct query term once
+	seen := make(map[string]bool)
+	for _, term := range idx.tokenizer.Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for _, p := range postings {
+			tf := float64(p.frequency)
+			norm := 1 - bm25B + bm25B*float64(idx.chunkLength[p.chunkID])/idx.avgLength
+			scores[p.chunkID] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	return scores
+}
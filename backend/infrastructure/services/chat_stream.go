@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// TokenHandler receives each piece of a streamed answer as it is generated.
+// Returning an error stops the stream.
+type TokenHandler func(token string) error
+
+// streamingClient returns an HTTP client for providers that stream answers.
+// timeout bounds the wait for response headers only, so a long streamed
+// answer is not cut off; the request context bounds the rest.
+func streamingClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = timeout
+	return &http.Client{Transport: transport}
+}
+
+// withTimeout bounds a non-streamed request, body included, by timeout. The
+// returned function attaches the timeout's cleanup to a successful response,
+// or releases it at once on failure.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, func(resp *http.Response, err error)) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func(resp *http.Response, err error) {
+		if err != nil {
+			cancel()
+			return
+		}
+		resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	}
+}
+
+// cancelOnClose cancels a request's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+	"ai-pdf-assistant-backend/proto"
+)
+
+// ContextBudget divides a model's context window between the parts of a
+// question prompt: system prompt, conversation history, retrieved chunks and
+// the tokens reserved for the answer
+type ContextBudget struct {
+	ContextWindow   int     // Tokens the model accepts, prompt and answer together
+	MaxPromptTokens int     // Most tokens spent on a prompt even when the window allows more; 0 for no cap
+	AnswerReserve   int     // Tokens kept free for the answer
+	HistoryShare    float64 // Share of the tokens left after the system prompt and question that history may use
+	HistoryReserve  int     // Tokens of the history share held back, e.g. for a summary of older messages
+}
+
+// DefaultContextBudget returns the budget defaults for a model's context window
+func DefaultContextBudget(contextWindow int) ContextBudget {
+	return ContextBudget{
+		ContextWindow:   contextWindow,
+		MaxPromptTokens: 16384,
+		AnswerReserve:   1024,
+		HistoryShare:    0.25,
+	}
+}
+
+// Validate checks the budget leaves room for a prompt
+func (b ContextBudget) Validate() error {
+	if b.ContextWindow <= 0 {
+		return fmt.Errorf("context window must be positive")
+	}
+	if b.AnswerReserve < 0 || b.AnswerReserve >= b.ContextWindow {
+		return fmt.Errorf("answer reserve must be between 0 and the context window (%d)", b.ContextWindow)
+	}
+	if b.HistoryShare < 0 || b.HistoryShare > 1 {
+		return fmt.Errorf("history share must be between 0 and 1")
+	}
+	return nil
+}
+
+// PromptAllocation is the part of a question prompt that fits in a ContextBudget
+type PromptAllocation struct {
+	History       []ChatMessage  // The most recent messages that fit, oldest first
+	Chunks        []*proto.Chunk // The highest-ranked chunks that fit, in rank order
+	DroppedChunks int            // Lower-ranked chunks left out
+	ContextTokens int            // Tokens available for document context once history is placed
+}
+
+// promptMarginTokens covers provider-specific instructions and message framing
+// beyond the shared answer prompt
+const promptMarginTokens = 128
+
+// Allocate fits history and ranked chunks into the budget. The system prompt,
+// question and answer reserve come first; history may then use up to its
+// share, newest messages first, and chunks fill the rest with the lowest-ranked
+// dropped first. A top chunk too large on its own is cut to fit.
+func (b ContextBudget) Allocate(question string, history []ChatMessage, chunks []*proto.Chunk) PromptAllocation {
+	available := b.ContextWindow - b.AnswerReserve
+	if b.MaxPromptTokens > 0 {
+		available = min(available, b.MaxPromptTokens)
+	}
+	overhead := tokenizer.Count(answerSystemPrompt) + tokenizer.Count(buildQuestionPrompt(contextHeader, question)) + promptMarginTokens
+	available -= overhead
+
+	var allocation PromptAllocation
+	if available <= 0 {
+		allocation.DroppedChunks = len(chunks)
+		return allocation
+	}
+
+	// History: newest messages first, within its share
+	historyBudget := max(int(float64(available)*b.HistoryShare)-b.HistoryReserve, 0)
+	used := 0
+	first := len(history)
+	for first > 0 {
+		cost := tokenizer.Count(history[first-1].Content) + 4 // Role and message framing
+		if used+cost > historyBudget {
+			break
+		}
+		used += cost
+		first--
+	}
+	allocation.History = history[first:]
+	allocation.ContextTokens = available - used
+
+	// Chunks: in rank order until the next one no longer fits
+	remaining := allocation.ContextTokens
+	for i, chunk := range chunks {
+		header := tokenizer.Count(chunkHeader(i, chunk)) + 1
+		cost := header + tokenizer.Count(chunk.Text)
+		if cost <= remaining {
+			allocation.Chunks = append(allocation.Chunks, chunk)
+			remaining -= cost
+			continue
+		}
+
+		if i == 0 && remaining > header {
+			cut := *chunk
+			cut.Text, _ = tokenizer.Truncate(chunk.Text, remaining-header)
+			allocation.Chunks = append(allocation.Chunks, &cut)
+		}
+		break
+	}
+	allocation.DroppedChunks = len(chunks) - len(allocation.Chunks)
+
+	return allocation
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// DenseSearch ranks chunks by cosine similarity between embeddings
+type DenseSearch struct {
+	embedder Embedder
+}
+
+// NewDenseSearch creates a dense retriever backed by the given embedder
+func NewDenseSearch(embedder Embedder) *DenseSearch {
+	return &DenseSearch{embedder: embedder}
+}
+
+// EmbedChunks fills Chunk.Embedding for every chunk that doesn't have one yet
+func (d *DenseSearch) EmbedChunks(ctx context.Context, chunks []*proto.Chunk) error {
+	var pending []*proto.Chunk
+	var texts []string
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) == 0 {
+			pending = append(pending, chunk)
+			texts = append(texts, chunk.Text)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	vectors, err := d.embedder.Embed(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to embed chunks: %w", err)
+	}
+	if len(vectors) != len(pending) {
+		return fmt.Errorf("expected %d embeddings, got %d", len(pending), len(vectors))
+	}
+
+	for i, chunk := range pending {
+		chunk.Embedding = vectors[i]
+	}
+
+	return nil
+}
+
+// FindRelevantChunks returns the topK chunks closest to the query embedding.
+// Chunks without embeddings are ignored.
+func (d *DenseSearch) FindRelevantChunks(ctx context.Context, chunks []*proto.Chunk, query string, topK int) ([]*proto.Chunk, error) {
+	ranked, err := d.RankChunks(ctx, chunks, query, topK)
+	if err != nil {
+		return nil, err
+	}
+
+	relevant := make([]*proto.Chunk, len(ranked))
+	for i, s := range ranked {
+		relevant[i] = s.Chunk
+	}
+
+	return relevant, nil
+}
+
+// RankChunks returns up to topK chunks with their cosine similarity to the query
+func (d *DenseSearch) RankChunks(ctx context.Context, chunks []*proto.Chunk, query string, topK int) ([]ScoredChunk, error) {
+	vectors, err := d.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("no embedding returned for query")
+	}
+	queryVector := vectors[0]
+
+	scored := make([]ScoredChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) == 0 {
+			continue
+		}
+		if score := cosineSimilarity(queryVector, chunk.Embedding); score > 0 {
+			scored = append(scored, ScoredChunk{Chunk: chunk, Score: score, Retriever: RetrieverDense})
+		}
+	}
+
+	return topScored(scored, topK), nil
+}
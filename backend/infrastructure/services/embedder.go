@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+)
+
+// Embedder turns texts into dense vectors for semantic search
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// HashingEmbedder is a deterministic, offline embedder based on feature hashing.
+// Words and their character trigrams are hashed into a fixed number of buckets,
+// so texts sharing word forms land close together without any model download.
+type HashingEmbedder struct {
+	dimensions int
+	tokenizer  *Tokenizer
+}
+
+// NewHashingEmbedder creates a hashing embedder producing vectors of the given size
+func NewHashingEmbedder(dimensions int) *HashingEmbedder {
+	if dimensions <= 0 {
+		dimensions = 256
+	}
+	return &HashingEmbedder{
+		dimensions: dimensions,
+		tokenizer:  NewTokenizer(true),
+	}
+}
+
+// Embed implements Embedder
+func (e *HashingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// embed builds a single L2-normalized vector
+func (e *HashingEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+
+	for _, token := range e.tokenizer.Tokenize(text) {
+		e.add(vector, token, 1.0)
+
+		// Character trigrams let related word forms share some weight
+		padded := "#" + token + "#"
+		for i := 0; i+3 <= len(padded); i++ {
+			e.add(vector, padded[i:i+3], 0.5)
+		}
+	}
+
+	normalize(vector)
+	return vector
+}
+
+// add hashes a feature into the vector; the sign bit keeps collisions unbiased
+func (e *HashingEmbedder) add(vector []float32, feature string, weight float32) {
+	h := fnv.New32a()
+	h.Write([]byte(feature))
+	sum := h.Sum32()
+
+	bucket := int(sum % uint32(e.dimensions))
+	if sum&(1<<31) != 0 {
+		weight = -weight
+	}
+	vector[bucket] += weight
+}
+
+// normalize scales a vector to unit length in place
+func normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return
+	}
+
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors.
+// Vectors of different sizes (e.g. from another embedding model) score 0.
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
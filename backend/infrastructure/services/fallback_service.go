@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FallbackConfig configures retries and circuit breaking for FallbackAIService
+type FallbackConfig struct {
+	MaxRetries       int           // Retries of a transient failure per provider, after the first attempt
+	BaseDelay        time.Duration // Backoff before the first retry; doubles for each further retry
+	MaxDelay         time.Duration // Longest wait before a retry; a longer Retry-After fails over instead
+	FailureThreshold int           // Consecutive failures that open a provider's circuit
+	Cooldown         time.Duration // How long an open circuit skips the provider before trying it again
+}
+
+// DefaultFallbackConfig returns the retry and circuit breaker defaults
+func DefaultFallbackConfig() FallbackConfig {
+	return FallbackConfig{
+		MaxRetries:       2,
+		BaseDelay:        500 * time.Millisecond,
+		MaxDelay:         8 * time.Second,
+		FailureThreshold: 3,
+		Cooldown:         30 * time.Second,
+	}
+}
+
+// FallbackAIService implements AIService over an ordered chain of providers.
+// Transient failures are retried with jittered exponential backoff, honoring
+// Retry-After; a provider that keeps failing has its circuit opened and the
+// next provider in the chain answers instead.
+type FallbackAIService struct {
+	providers []*fallbackProvider
+	config    FallbackConfig
+	sleep     func(ctx context.Context, d time.Duration) error
+}
+
+// fallbackProvider is a provider in the chain with its circuit breaker
+type fallbackProvider struct {
+	service AIService
+	breaker *circuitBreaker
+}
+
+// NewFallbackAIService creates a fallback chain, trying providers in the order given
+func NewFallbackAIService(providers []AIService, config FallbackConfig) *FallbackAIService {
+	chain := make([]*fallbackProvider, len(providers))
+	for i, provider := range providers {
+		chain[i] = &fallbackProvider{
+			service: provider,
+			breaker: &circuitBreaker{threshold: config.FailureThreshold, cooldown: config.Cooldown, now: time.Now},
+		}
+	}
+
+	return &FallbackAIService{
+		providers: chain,
+		config:    config,
+		sleep:     sleepContext,
+	}
+}
+
+// ModelInfo implements AIService, naming the first provider in the chain. The
+// context window is the smallest in the chain, so prompts fit whichever answers.
+func (s *FallbackAIService) ModelInfo() ModelInfo {
+	info := s.providers[0].service.ModelInfo()
+	for _, provider := range s.providers[1:] {
+		info.ContextWindow = min(info.ContextWindow, provider.service.ModelInfo().ContextWindow)
+	}
+	return info
+}
+
+// AnswerQuestion implements AIService
+func (s *FallbackAIService) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
+	var answer string
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		var err error
+		answer, err = provider.AnswerQuestion(ctx, docContext, question, history)
+		return true, err
+	})
+	return answer, err
+}
+
+// StreamAnswer implements AIService. Once a provider has streamed a token the
+// answer cannot be restarted elsewhere, so a failure after that is returned as is.
+func (s *FallbackAIService) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	var answer string
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		streamed := false
+		var err error
+		answer, err = provider.StreamAnswer(ctx, docContext, question, history, func(token string) error {
+			streamed = true
+			return onToken(token)
+		})
+		return !streamed, err
+	})
+	return answer, err
+}
+
+// GenerateSummary implements AIService
+func (s *FallbackAIService) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
+	var result *SummaryResult
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		var err error
+		result, err = provider.GenerateSummary(ctx, text)
+		return true, err
+	})
+	return result, err
+}
+
+// CheckGrounding implements AIService
+func (s *FallbackAIService) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	var grounding Grounding
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		var err error
+		grounding, err = provider.CheckGrounding(ctx, docContext, question, answer)
+		return true, err
+	})
+	return grounding, err
+}
+
+// RewriteQuery implements AIService
+func (s *FallbackAIService) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	var query string
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		var err error
+		query, err = provider.RewriteQuery(ctx, question, history)
+		return true, err
+	})
+	return query, err
+}
+
+// SummarizeHistory implements AIService
+func (s *FallbackAIService) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	var summary string
+	err := s.run(ctx, func(provider AIService) (bool, error) {
+		var err error
+		summary, err = provider.SummarizeHistory(ctx, previous, messages)
+		return true, err
+	})
+	return summary, err
+}
+
+// run calls each available provider in turn until one succeeds, recording the
+// one that answered in ctx. call reports whether its failure may be retried.
+func (s *FallbackAIService) run(ctx context.Context, call func(provider AIService) (bool, error)) error {
+	var failures []string
+	var lastErr error
+
+	for _, provider := range s.providers {
+		info := provider.service.ModelInfo()
+		if !provider.breaker.allow() {
+			failures = append(failures, info.Provider+": circuit open")
+			continue
+		}
+
+		retryable, err := s.attempt(ctx, provider.service, call)
+		if err == nil {
+			provider.breaker.success()
+			recordAnswerSource(ctx, info)
+			return nil
+		}
+		if ctx.Err() != nil {
+			provider.breaker.release()
+			return err
+		}
+
+		provider.breaker.failure()
+		fmt.Printf("Warning: AI provider %s failed: %v\n", info.Provider, err)
+		failures = append(failures, info.Provider)
+		lastErr = err
+		if !retryable {
+			return err
+		}
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("no AI provider available (%s)", strings.Join(failures, ", "))
+	}
+	return fmt.Errorf("all AI providers failed (%s): %w", strings.Join(failures, ", "), lastErr)
+}
+
+// attempt calls one provider, retrying transient failures with backoff. It
+// reports whether the final failure may be passed to the next provider.
+func (s *FallbackAIService) attempt(ctx context.Context, provider AIService, call func(provider AIService) (bool, error)) (bool, error) {
+	for retry := 0; ; retry++ {
+		retryable, err := call(provider)
+		if err == nil || !retryable || ctx.Err() != nil {
+			return retryable, err
+		}
+		if !isTransient(err) || retry >= s.config.MaxRetries {
+			return true, err
+		}
+
+		delay := s.backoff(retry)
+		if wait := retryAfter(err); wait > 0 {
+			if wait > s.config.MaxDelay {
+				return true, err // Not worth waiting for; fail over instead
+			}
+			delay = wait
+		}
+		if err := s.sleep(ctx, delay); err != nil {
+			return false, err
+		}
+	}
+}
+
+// backoff returns the jittered delay before a retry: half the exponential
+// delay plus a random share of the other half
+func (s *FallbackAIService) backoff(retry int) time.Duration {
+	delay := s.config.BaseDelay << retry
+	if delay > s.config.MaxDelay || delay <= 0 {
+		delay = s.config.MaxDelay
+	}
+	half := delay / 2
+	if half <= 0 {
+		return delay
+	}
+	return half + rand.N(half+1)
+}
+
+// circuitBreaker stops calls to a provider after repeated failures. After the
+// cooldown one trial call is let through: success closes the circuit, failure
+// opens it for another cooldown.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	failures  int
+	openUntil time.Time
+	trial     bool // A half-open trial call is in flight
+}
+
+// allow reports whether a call may be made now
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// success closes the circuit
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.trial = false
+}
+
+// failure counts a failed call, opening the circuit at the threshold
+func (b *circuitBreaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.threshold > 0 && b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// release ends a call that neither succeeded nor failed, such as one the caller cancelled
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+}
+
+// AnswerSource collects the providers that answered AI calls made with a context
+type AnswerSource struct {
+	mu       sync.Mutex
+	answered []ModelInfo
+}
+
+type answerSourceKey struct{}
+
+// WithAnswerSource returns a context that records which providers answer calls made with it
+func WithAnswerSource(ctx context.Context) (context.Context, *AnswerSource) {
+	source := &AnswerSource{}
+	return context.WithValue(ctx, answerSourceKey{}, source), source
+}
+
+// recordAnswerSource notes the provider that answered, if ctx is recording
+func recordAnswerSource(ctx context.Context, info ModelInfo) {
+	if source, ok := ctx.Value(answerSourceKey{}).(*AnswerSource); ok {
+		source.mu.Lock()
+		source.answered = append(source.answered, info)
+		source.mu.Unlock()
+	}
+}
+
+// Last returns the provider that answered most recently, or fallback if none was recorded
+func (s *AnswerSource) Last(fallback ModelInfo) ModelInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.answered) == 0 {
+		return fallback
+	}
+	return s.answered[len(s.answered)-1]
+}
+
+// OnlyFrom reports whether every recorded answer came from the given provider
+func (s *AnswerSource) OnlyFrom(info ModelInfo) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, answered := range s.answered {
+		if !answered.Is(info) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+)
+
+// scriptedProvider answers with the scripted errors in turn, then succeeds
+type scriptedProvider struct {
+	AIService // Methods the tests do not call are left nil
+	name      string
+	errs      []error
+	calls     int
+	onCall    func() // Runs before each answer, if set
+}
+
+func (p *scriptedProvider) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: p.name, Model: p.name + "-model", ContextWindow: 8192}
+}
+
+func (p *scriptedProvider) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
+	p.calls++
+	if p.onCall != nil {
+		p.onCall()
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if p.calls <= len(p.errs) {
+		return "", p.errs[p.calls-1]
+	}
+	return "answer from " + p.name, nil
+}
+
+// fakeClock stands in for the breakers' clock and the retry sleep
+type fakeClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+// newTestFallback builds a fallback chain that runs on clock
+func newTestFallback(config FallbackConfig, clock *fakeClock, providers ...*scriptedProvider) *FallbackAIService {
+	services := make([]AIService, len(providers))
+	for i, provider := range providers {
+		services[i] = provider
+	}
+
+	s := NewFallbackAIService(services, config)
+	s.sleep = clock.sleep
+	for _, provider := range s.providers {
+		provider.breaker.now = clock.Now
+	}
+	return s
+}
+
+func statusErr(status int, retryAfter time.Duration) error {
+	return &chatapi.APIError{StatusCode: status, Status: http.StatusText(status), RetryAfter: retryAfter}
+}
+
+func TestFallbackRetries(t *testing.T) {
+	config := FallbackConfig{
+		MaxRetries:       2,
+		BaseDelay:        100 * time.Millisecond,
+		MaxDelay:         time.Second,
+		FailureThreshold: 5,
+		Cooldown:         time.Minute,
+	}
+
+	tests := []struct {
+		name       string
+		errs       []error
+		wantAnswer string
+		wantCalls  int
+		wantSleeps int
+		wantDelay  time.Duration // 0 expects jittered backoff
+	}{
+		{
+			name:       "transient failures are retried",
+			errs:       []error{statusErr(http.StatusServiceUnavailable, 0), statusErr(http.StatusTooManyRequests, 0)},
+			wantAnswer: "answer from primary",
+			wantCalls:  3,
+			wantSleeps: 2,
+		},
+		{
+			name:       "retries stop at MaxRetries and fail over",
+			errs:       []error{statusErr(500, 0), statusErr(502, 0), statusErr(503, 0)},
+			wantAnswer: "answer from secondary",
+			wantCalls:  3,
+			wantSleeps: 2,
+		},
+		{
+			name:       "Retry-After within MaxDelay is waited for",
+			errs:       []error{statusErr(http.StatusTooManyRequests, 700*time.Millisecond)},
+			wantAnswer: "answer from primary",
+			wantCalls:  2,
+			wantSleeps: 1,
+			wantDelay:  700 * time.Millisecond,
+		},
+		{
+			name:       "Retry-After beyond MaxDelay fails over at once",
+			errs:       []error{statusErr(http.StatusTooManyRequests, time.Minute)},
+			wantAnswer: "answer from secondary",
+			wantCalls:  1,
+		},
+		{
+			name:       "client errors are not retried",
+			errs:       []error{statusErr(http.StatusBadRequest, 0)},
+			wantAnswer: "answer from secondary",
+			wantCalls:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			primary := &scriptedProvider{name: "primary", errs: tt.errs}
+			secondary := &scriptedProvider{name: "secondary"}
+			s := newTestFallback(config, clock, primary, secondary)
+
+			answer, err := s.AnswerQuestion(context.Background(), "", "question", nil)
+			if err != nil {
+				t.Fatalf("AnswerQuestion: %v", err)
+			}
+			if answer != tt.wantAnswer {
+				t.Errorf("answer = %q, want %q", answer, tt.wantAnswer)
+			}
+			if primary.calls != tt.wantCalls {
+				t.Errorf("primary called %d times, want %d", primary.calls, tt.wantCalls)
+			}
+			if len(clock.sleeps) != tt.wantSleeps {
+				t.Fatalf("slept %v, want %d sleeps", clock.sleeps, tt.wantSleeps)
+			}
+			for _, d := range clock.sleeps {
+				if tt.wantDelay != 0 && d != tt.wantDelay {
+					t.Errorf("slept %v, want %v", d, tt.wantDelay)
+				}
+				if d <= 0 || d > config.MaxDelay {
+					t.Errorf("slept %v, outside (0, %v]", d, config.MaxDelay)
+				}
+			}
+		})
+	}
+}
+
+func TestFallbackHalfOpenTrial(t *testing.T) {
+	config := FallbackConfig{FailureThreshold: 1, Cooldown: 30 * time.Second}
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	badRequest := statusErr(http.StatusBadRequest, 0)
+	primary := &scriptedProvider{name: "primary", errs: []error{badRequest, badRequest}}
+	secondary := &scriptedProvider{name: "secondary"}
+	s := newTestFallback(config, clock, primary, secondary)
+
+	steps := []struct {
+		name      string
+		advance   time.Duration
+		wantCalls int // Calls to primary so far
+	}{
+		{"the failure opens the circuit", 0, 1},
+		{"the open circuit skips the provider", 10 * time.Second, 1},
+		{"after the cooldown a failed trial reopens it", 25 * time.Second, 2},
+		{"the reopened circuit skips the provider", 10 * time.Second, 2},
+		{"a successful trial closes it", 25 * time.Second, 3},
+		{"the closed circuit lets calls through", 0, 4},
+	}
+
+	for _, step := range steps {
+		clock.now = clock.now.Add(step.advance)
+		if _, err := s.AnswerQuestion(context.Background(), "", "question", nil); err != nil {
+			t.Fatalf("%s: AnswerQuestion: %v", step.name, err)
+		}
+		if primary.calls != step.wantCalls {
+			t.Errorf("%s: primary called %d times, want %d", step.name, primary.calls, step.wantCalls)
+		}
+	}
+}
+
+func TestCircuitBreakerAllowsOneTrial(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	b := &circuitBreaker{threshold: 1, cooldown: time.Second, now: clock.Now}
+
+	b.failure()
+	if b.allow() {
+		t.Fatal("open circuit allowed a call")
+	}
+	clock.now = clock.now.Add(2 * time.Second)
+	if !b.allow() {
+		t.Fatal("circuit did not allow a trial after the cooldown")
+	}
+	if b.allow() {
+		t.Error("circuit allowed a second call during the trial")
+	}
+	b.success()
+	if !b.allow() || !b.allow() {
+		t.Error("closed circuit refused calls")
+	}
+}
+
+func TestFallbackReleasesTrialOnCancel(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *FallbackAIService, primary *scriptedProvider, clock *fakeClock, cancel context.CancelFunc)
+	}{
+		{
+			name: "cancelled during the call",
+			setup: func(s *FallbackAIService, primary *scriptedProvider, clock *fakeClock, cancel context.CancelFunc) {
+				primary.onCall = cancel
+			},
+		},
+		{
+			name: "cancelled while waiting to retry",
+			setup: func(s *FallbackAIService, primary *scriptedProvider, clock *fakeClock, cancel context.CancelFunc) {
+				primary.errs = append(primary.errs, statusErr(http.StatusServiceUnavailable, 0))
+				s.sleep = func(ctx context.Context, d time.Duration) error {
+					cancel()
+					return clock.sleep(ctx, d)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := FallbackConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Second, FailureThreshold: 1, Cooldown: 30 * time.Second}
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			primary := &scriptedProvider{name: "primary", errs: []error{statusErr(http.StatusBadRequest, 0)}}
+			secondary := &scriptedProvider{name: "secondary"}
+			s := newTestFallback(config, clock, primary, secondary)
+
+			// Open the circuit, then let the cooldown pass so the next call is a trial
+			if _, err := s.AnswerQuestion(context.Background(), "", "question", nil); err != nil {
+				t.Fatalf("AnswerQuestion: %v", err)
+			}
+			clock.now = clock.now.Add(time.Minute)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tt.setup(s, primary, clock, cancel)
+
+			if _, err := s.AnswerQuestion(ctx, "", "question", nil); !errors.Is(err, context.Canceled) {
+				t.Fatalf("err = %v, want context.Canceled", err)
+			}
+			if secondary.calls != 1 {
+				t.Error("the cancelled call failed over to the next provider")
+			}
+
+			// The trial was released, not counted as a failure, so the provider gets a new one at once
+			primary.onCall = nil
+			s.sleep = clock.sleep
+			answer, err := s.AnswerQuestion(context.Background(), "", "question", nil)
+			if err != nil {
+				t.Fatalf("AnswerQuestion after cancel: %v", err)
+			}
+			if answer != "answer from primary" {
+				t.Errorf("answer = %q, want the primary's answer", answer)
+			}
+		})
+	}
+}
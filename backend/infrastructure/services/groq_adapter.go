@@ -1,7 +1,7 @@
 package services
 
 import (
-	"strings"
+	"context"
 
 	appservices "ai-pdf-assistant-backend/services"
 )
@@ -16,44 +16,82 @@ func NewGroqAIServiceAdapter(groq *appservices.GroqService) *GroqAIServiceAdapte
 	return &GroqAIServiceAdapter{groq: groq}
 }
 
+// ModelInfo implements AIService interface
+func (a *GroqAIServiceAdapter) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: "groq", Model: a.groq.Model(), ContextWindow: a.groq.ContextWindow()}
+}
+
 // AnswerQuestion implements AIService interface using Groq
-func (a *GroqAIServiceAdapter) AnswerQuestion(context string, question string, history []string) (string, bool, error) {
-	// Convert history strings to ChatMessage format
-	var chatHistory []appservices.ChatMessage
-	for i, msg := range history {
-		role := "user"
-		if i%2 == 1 {
-			role = "assistant"
-		}
-		chatHistory = append(chatHistory, appservices.ChatMessage{
-			Role:    role,
-			Content: msg,
-		})
+func (a *GroqAIServiceAdapter) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
+	resp, err := a.groq.ChatWithContext(ctx, docContext, question, toChatHistory(history), "")
+	if err != nil {
+		return "", err
 	}
 
-	resp, err := a.groq.ChatWithContext(context, question, chatHistory, "")
+	return resp.Message, nil
+}
+
+// StreamAnswer implements AIService interface using Groq's streaming API
+func (a *GroqAIServiceAdapter) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	resp, err := a.groq.StreamChatWithContext(ctx, docContext, question, toChatHistory(history), "", onToken)
 	if err != nil {
-		return "", false, err
+		return "", err
 	}
 
-	// Check if answer was found in context
-	answerFound := !strings.Contains(strings.ToLower(resp.Message), "not found") &&
-		!strings.Contains(strings.ToLower(resp.Message), "not available") &&
-		!strings.Contains(strings.ToLower(resp.Message), "cannot find")
+	return resp.Message, nil
+}
 
-	return resp.Message, answerFound, nil
+// toChatHistory converts history to Groq's ChatMessage format
+func toChatHistory(history []ChatMessage) []appservices.ChatMessage {
+	chatHistory := make([]appservices.ChatMessage, len(history))
+	for i, msg := range history {
+		chatHistory[i] = appservices.ChatMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+	}
+	return chatHistory
 }
 
-// GenerateSummary implements AIService interface using Groq
-func (a *GroqAIServiceAdapter) GenerateSummary(text string) (string, []string, []string, error) {
-	summary, err := a.groq.SummarizePDF(text)
-	if err != nil {
-		return "", nil, nil, err
+// CheckGrounding implements AIService interface using Groq's JSON mode
+func (a *GroqAIServiceAdapter) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	return checkGrounding(ctx, docContext, question, answer, a.completeJSON(groundingAnswerTokens))
+}
+
+// GenerateSummary implements AIService interface using Groq's JSON mode
+func (a *GroqAIServiceAdapter) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
+	return generateStructuredSummary(ctx, text, a.groq.ContextWindow(), a.completeJSON(SummaryAnswerTokens))
+}
+
+// RewriteQuery implements AIService
+func (a *GroqAIServiceAdapter) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	return rewriteQuery(ctx, question, history, a.complete(100))
+}
+
+// SummarizeHistory implements AIService
+func (a *GroqAIServiceAdapter) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	return summarizeHistory(ctx, previous, messages, a.complete(historyAnswerTokens))
+}
+
+// complete adapts Groq's plain completion to a completeFunc
+func (a *GroqAIServiceAdapter) complete(maxTokens int) completeFunc {
+	return func(ctx context.Context, messages []Message) (string, error) {
+		return a.groq.Complete(ctx, toGroqMessages(messages), maxTokens)
 	}
+}
 
-	// Extract takeaways and topics from summary
-	takeaways := extractTakeaways(summary)
-	topics := extractTopics(summary)
+// completeJSON adapts Groq's JSON mode to a completeFunc
+func (a *GroqAIServiceAdapter) completeJSON(maxTokens int) completeFunc {
+	return func(ctx context.Context, messages []Message) (string, error) {
+		return a.groq.CompleteJSON(ctx, toGroqMessages(messages), maxTokens)
+	}
+}
 
-	return summary, takeaways, topics, nil
+// toGroqMessages converts chat messages to Groq's message format
+func toGroqMessages(messages []Message) []appservices.GroqMessage {
+	groqMessages := make([]appservices.GroqMessage, len(messages))
+	for i, msg := range messages {
+		groqMessages[i] = appservices.GroqMessage{Role: msg.Role, Content: msg.Content}
+	}
+	return groqMessages
 }
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Grounding is a verdict on whether the document context supports an answer
+type Grounding struct {
+	AnswerFound bool    `json:"answer_found"` // The answer gives the information asked for, backed by the context
+	Confidence  float64 `json:"confidence"`   // 0 to 1: how sure the verdict is
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// noAnswerReply is the reply the answer prompts ask for when the context lacks the answer
+const noAnswerReply = "I cannot find this information in the document."
+
+// groundingAnswerTokens bounds the reply of a grounding check
+const groundingAnswerTokens = 200
+
+// groundingSystemPrompt asks for a JSON verdict on an answer
+const groundingSystemPrompt = `You check answers against document excerpts. Respond with a single JSON object and nothing else:
+{"answer_found": true or false, "confidence": a number from 0 to 1, "reason": "one short sentence"}
+
+"answer_found" is true only if the answer gives the information the question asks for and the excerpts support what it says. It is false if the answer says the information is missing, or if it relies on claims the excerpts do not support. Saying that something is "not available" can still be a supported answer when the excerpts say so.
+"confidence" is how sure you are of your verdict: near 1 when the excerpts clearly settle it, near 0.5 when they are ambiguous.`
+
+// checkGrounding asks a provider whether docContext supports the answer
+func checkGrounding(ctx context.Context, docContext string, question string, answer string, complete completeFunc) (Grounding, error) {
+	reply, err := complete(ctx, []Message{
+		{Role: "system", Content: groundingSystemPrompt},
+		{Role: "user", Content: fmt.Sprintf("%s\n\nQuestion: %s\n\nAnswer: %s", docContext, question, answer)},
+	})
+	if err != nil {
+		return Grounding{}, err
+	}
+
+	return parseGrounding(reply)
+}
+
+// parseGrounding reads a grounding verdict from a model reply, tolerating
+// code fences, surrounding prose and trailing commas
+func parseGrounding(reply string) (Grounding, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return Grounding{}, fmt.Errorf("no JSON object found in grounding verdict")
+	}
+	raw := reply[start : end+1]
+
+	var verdict struct {
+		AnswerFound *bool    `json:"answer_found"`
+		Confidence  *float64 `json:"confidence"`
+		Reason      string   `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(raw), &verdict); err != nil {
+		if json.Unmarshal([]byte(trailingCommas.ReplaceAllString(raw, "$1")), &verdict) != nil {
+			return Grounding{}, fmt.Errorf("invalid grounding verdict: %w", err)
+		}
+	}
+	if verdict.AnswerFound == nil {
+		return Grounding{}, fmt.Errorf(`grounding verdict has no "answer_found"`)
+	}
+
+	grounding := Grounding{
+		AnswerFound: *verdict.AnswerFound,
+		Confidence:  0.5,
+		Reason:      strings.TrimSpace(verdict.Reason),
+	}
+	if verdict.Confidence != nil {
+		grounding.Confidence = min(max(*verdict.Confidence, 0), 1)
+	}
+	return grounding, nil
+}
+
+// HeuristicGrounding judges an answer without a model: an answer that opens
+// with the no-answer reply is not found, and otherwise the answer is found if
+// most of its sentences mostly use words from the context. It stands in for
+// a provider whose check fails or is turned off.
+func HeuristicGrounding(docContext string, answer string) Grounding {
+	spans := sentenceSpans(answer)
+	if len(spans) > 0 && strings.EqualFold(answer[spans[0][0]:spans[0][1]], noAnswerReply) {
+		return Grounding{Confidence: 0.9, Reason: "The answer says the document does not contain the information."}
+	}
+
+	tokenizer := NewTokenizer(true)
+	known := make(map[string]bool)
+	for _, term := range tokenizer.Tokenize(docContext) {
+		known[term] = true
+	}
+
+	sentences, supported := 0, 0
+	for _, span := range spans {
+		terms := tokenizer.Tokenize(citationMarker.ReplaceAllString(answer[span[0]:span[1]], ""))
+		if len(terms) == 0 || !strings.ContainsFunc(answer[span[0]:span[1]], unicode.IsLetter) {
+			continue
+		}
+		found := 0
+		for _, term := range terms {
+			if known[term] {
+				found++
+			}
+		}
+		sentences++
+		if float64(found) >= 0.6*float64(len(terms)) {
+			supported++
+		}
+	}
+	if sentences == 0 {
+		return Grounding{Confidence: 1, Reason: "The answer is empty."}
+	}
+
+	share := float64(supported) / float64(sentences)
+	grounding := Grounding{
+		AnswerFound: share >= 0.5,
+		Confidence:  share,
+		Reason:      fmt.Sprintf("%d of %d sentences use words from the document.", supported, sentences),
+	}
+	if !grounding.AnswerFound {
+		grounding.Confidence = 1 - share
+	}
+	return grounding
+}
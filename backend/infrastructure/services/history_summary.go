@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// historySystemPrompt asks for a running summary of a conversation
+const historySystemPrompt = `You keep a running summary of a conversation between a user and an assistant about the user's documents. Merge the summary so far, if any, with the later messages into one updated summary. Keep what the user asked about, the answers and facts the assistant gave, names, numbers and documents mentioned, and anything the user may refer back to. Write plain prose of a few sentences, in the third person, and reply with the summary only.`
+
+// historyAnswerTokens bounds the reply of a conversation summary
+const historyAnswerTokens = 400
+
+// summarizeHistory asks a provider to extend the summary of a conversation with later messages
+func summarizeHistory(ctx context.Context, previous string, messages []ChatMessage, complete completeFunc) (string, error) {
+	reply, err := complete(ctx, []Message{
+		{Role: "system", Content: historySystemPrompt},
+		{Role: "user", Content: buildHistoryPrompt(previous, messages)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	summary := strings.TrimSpace(reply)
+	if summary == "" {
+		return "", fmt.Errorf("empty conversation summary")
+	}
+	return summary, nil
+}
+
+// buildHistoryPrompt shows the summary so far and the messages to add to it
+func buildHistoryPrompt(previous string, messages []ChatMessage) string {
+	var builder strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&builder, "Summary so far:\n%s\n\nLater messages:\n", previous)
+	} else {
+		builder.WriteString("Conversation:\n")
+	}
+	for _, msg := range messages {
+		fmt.Fprintf(&builder, "%s: %s\n", speaker(msg.Role), msg.Content)
+	}
+	builder.WriteString("\nUpdated summary:")
+
+	return builder.String()
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// Retriever names reported alongside each retrieved chunk
+const (
+	RetrieverKeyword  = "keyword"
+	RetrieverDense    = "dense"
+	RetrieverHybrid   = "hybrid"   // Found by both retrievers
+	RetrieverFallback = "fallback" // Nothing matched; leading chunks were used
+)
+
+// maxTopK caps how many chunks a single request may pull into the prompt
+const maxTopK = 100
+
+// ScoredChunk is a retrieved chunk with its score and the retriever that produced it
+type ScoredChunk struct {
+	Chunk     *proto.Chunk
+	Score     float64
+	Retriever string
+}
+
+// RetrievalConfig tunes hybrid retrieval
+type RetrievalConfig struct {
+	TopK          int     // Number of chunks passed to the model
+	KeywordWeight float64 // Weight of the BM25 ranking in the fusion
+	DenseWeight   float64 // Weight of the embedding ranking in the fusion
+	RRFK          int     // Reciprocal rank fusion constant; larger values flatten rank differences
+	RewriteQuery  bool    // Rewrite follow-up questions into standalone search queries before searching
+}
+
+// DefaultRetrievalConfig returns the settings used when nothing is configured
+func DefaultRetrievalConfig() RetrievalConfig {
+	return RetrievalConfig{
+		TopK:          20,
+		KeywordWeight: 1.0,
+		DenseWeight:   1.0,
+		RRFK:          60,
+		RewriteQuery:  true,
+	}
+}
+
+// Validate checks the configuration is usable
+func (c RetrievalConfig) Validate() error {
+	if c.TopK < 1 || c.TopK > maxTopK {
+		return fmt.Errorf("top_k must be between 1 and %d", maxTopK)
+	}
+	if c.KeywordWeight < 0 || c.DenseWeight < 0 {
+		return fmt.Errorf("retriever weights must not be negative")
+	}
+	if c.KeywordWeight == 0 && c.DenseWeight == 0 {
+		return fmt.Errorf("at least one retriever weight must be positive")
+	}
+	if c.RRFK < 1 {
+		return fmt.Errorf("rrf_k must be positive")
+	}
+	return nil
+}
+
+// HybridSearch fuses keyword and dense rankings with weighted reciprocal rank fusion
+type HybridSearch struct {
+	keyword *VectorSearch
+	dense   *DenseSearch // Optional; keyword-only when nil
+}
+
+// NewHybridSearch creates a hybrid retriever
+func NewHybridSearch(keyword *VectorSearch, dense *DenseSearch) *HybridSearch {
+	return &HybridSearch{keyword: keyword, dense: dense}
+}
+
+// Validate checks cfg can be served by this retriever: keyword search may only
+// be turned off when there is a dense retriever to take its place
+func (h *HybridSearch) Validate(cfg RetrievalConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if h.dense == nil && cfg.KeywordWeight == 0 {
+		return fmt.Errorf("keyword_weight must be positive when no dense retriever is configured")
+	}
+	return nil
+}
+
+// Search returns up to cfg.TopK chunks ranked by fused score
+func (h *HybridSearch) Search(ctx context.Context, chunks []*proto.Chunk, query string, cfg RetrievalConfig) []ScoredChunk {
+	if len(chunks) == 0 {
+		return []ScoredChunk{}
+	}
+
+	// Let each retriever contribute a deeper candidate list than the final cut
+	depth := cfg.TopK * 3
+
+	var rankings [][]ScoredChunk
+	var weights []float64
+	if cfg.KeywordWeight > 0 {
+		rankings = append(rankings, h.keyword.RankChunks(chunks, query, depth))
+		weights = append(weights, cfg.KeywordWeight)
+	}
+	if h.dense != nil && cfg.DenseWeight > 0 {
+		ranked, err := h.dense.RankChunks(ctx, chunks, query, depth)
+		if err != nil {
+			fmt.Printf("Warning: Dense search failed, using keyword results only: %v\n", err)
+			if cfg.KeywordWeight == 0 {
+				rankings = append(rankings, h.keyword.RankChunks(chunks, query, depth))
+				weights = append(weights, 1)
+			}
+		} else {
+			rankings = append(rankings, ranked)
+			weights = append(weights, cfg.DenseWeight)
+		}
+	}
+
+	fused := fuseRankings(rankings, weights, cfg.RRFK)
+
+	// If nothing matched, return the leading chunks so the model still gets context
+	if len(fused) == 0 {
+		for _, chunk := range chunks {
+			fused = append(fused, ScoredChunk{Chunk: chunk, Retriever: RetrieverFallback})
+			if len(fused) >= cfg.TopK {
+				break
+			}
+		}
+		return fused
+	}
+
+	return topScored(fused, cfg.TopK)
+}
+
+// fuseRankings combines ranked lists with weighted reciprocal rank fusion:
+// score = sum(weight / (k + rank)) over every list the chunk appears in.
+func fuseRankings(rankings [][]ScoredChunk, weights []float64, k int) []ScoredChunk {
+	fused := make(map[string]*ScoredChunk)
+	var order []string
+
+	for i, ranking := range rankings {
+		for rank, s := range ranking {
+			contribution := weights[i] / float64(k+rank+1)
+
+			existing, ok := fused[s.Chunk.Id]
+			if !ok {
+				fused[s.Chunk.Id] = &ScoredChunk{Chunk: s.Chunk, Score: contribution, Retriever: s.Retriever}
+				order = append(order, s.Chunk.Id)
+				continue
+			}
+			existing.Score += contribution
+			if existing.Retriever != s.Retriever {
+				existing.Retriever = RetrieverHybrid
+			}
+		}
+	}
+
+	results := make([]ScoredChunk, len(order))
+	for i, id := range order {
+		results[i] = *fused[id]
+	}
+
+	return results
+}
+
+// topScored sorts chunks by score descending and keeps at most topK
+func topScored(scored []ScoredChunk, topK int) []ScoredChunk {
+	sort.SliceStable(scored, func(i, j int) bool {
+		return scored[i].Score > scored[j].Score
+	})
+
+	if len(scored) > topK {
+		scored = scored[:topK]
+	}
+
+	return scored
+}
@@ -1,11 +1,19 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"ai-pdf-assistant-backend/proto"
 )
 
+// pageMarker matches the [Page N] markers in text sent for summarizing
+var pageMarker = regexp.MustCompile(`\[Pages? (\d+)`)
+
 // MockAIService provides mock AI responses for development/testing
 type MockAIService struct {
 }
@@ -15,71 +23,171 @@ func NewMockAIService() *MockAIService {
 	return &MockAIService{}
 }
 
+// ModelInfo implements AIService
+func (s *MockAIService) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: "mock", Model: "mock", ContextWindow: 8192}
+}
+
 // AnswerQuestion provides a mock answer based on simple keyword matching
-func (s *MockAIService) AnswerQuestion(context string, question string, history []string) (string, bool, error) {
+func (s *MockAIService) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
 	// Simulate API delay
-	time.Sleep(500 * time.Millisecond)
-
-	questionLower := strings.ToLower(question)
-	contextLower := strings.ToLower(context)
+	if err := sleepContext(ctx, 500*time.Millisecond); err != nil {
+		return "", err
+	}
 
 	// Simple keyword matching to determine if answer might be in context
-	answerFound := false
 	answer := ""
+	questionWords := strings.Fields(strings.ToLower(question))
+	if matches, _ := mockKeywordMatches(docContext, questionWords); matches > 0 {
+		// Generate a mock answer citing the chunk that shares most words with the question
+		marker := ""
+		if chunk := mockCitedChunk(docContext, questionWords); chunk > 0 {
+			marker = fmt.Sprintf(" [%d]", chunk)
+		}
+		answer = fmt.Sprintf("Based on the document, %s%s. The document mentions relevant information about this topic%s. [This is a mock response - connect to a real AI service for actual answers.]", strings.TrimRight(question, "?.! "), marker, marker)
+	} else {
+		answer = noAnswerReply + " [Mock response - connect to a real AI service for actual answers.]"
+	}
 
-	// Check if question keywords appear in context
-	questionWords := strings.Fields(questionLower)
-	matches := 0
+	return answer, nil
+}
+
+// mockKeywordMatches counts the question keywords that appear in the context,
+// out of all keywords
+func mockKeywordMatches(docContext string, questionWords []string) (int, int) {
+	contextLower := strings.ToLower(docContext)
+	matches, keywords := 0, 0
 	for _, word := range questionWords {
-		if len(word) > 3 && strings.Contains(contextLower, word) {
-			matches++
+		if len(word) > 3 {
+			keywords++
+			if strings.Contains(contextLower, word) {
+				matches++
+			}
 		}
 	}
+	return matches, keywords
+}
 
-	if matches > 0 {
-		answerFound = true
-		// Generate a mock answer
-		answer = fmt.Sprintf("Based on the document, %s. The document mentions relevant information about this topic. [This is a mock response - connect to a real AI service for actual answers.]", question)
-	} else {
-		answerFound = false
-		answer = "I cannot find this information in the document. [Mock response - connect to a real AI service for actual answers.]"
+// mockChunkHeader finds the chunk headers written by BuildContext
+var mockChunkHeader = regexp.MustCompile(`(?m)^\[Chunk (\d+) - [^\]]*\]$`)
+
+// mockCitedChunk returns the number of the context chunk containing the most
+// question words, or 0 if the context is not split into chunks
+func mockCitedChunk(docContext string, questionWords []string) int {
+	headers := mockChunkHeader.FindAllStringSubmatchIndex(docContext, -1)
+	best, bestMatches := 0, 0
+	for i, header := range headers {
+		end := len(docContext)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		text := strings.ToLower(docContext[header[1]:end])
+
+		matches := 0
+		for _, word := range questionWords {
+			if len(word) > 3 && strings.Contains(text, word) {
+				matches++
+			}
+		}
+		if matches > bestMatches {
+			best, _ = strconv.Atoi(docContext[header[2]:header[3]])
+			bestMatches = matches
+		}
+	}
+	return best
+}
+
+// StreamAnswer streams the mock answer word by word
+func (s *MockAIService) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	answer, err := s.AnswerQuestion(ctx, docContext, question, history)
+	if err != nil {
+		return "", err
+	}
+
+	for _, token := range strings.SplitAfter(answer, " ") {
+		if err := onToken(token); err != nil {
+			return "", err
+		}
+		// Simulate generation speed
+		if err := sleepContext(ctx, 20*time.Millisecond); err != nil {
+			return "", err
+		}
 	}
 
-	return answer, answerFound, nil
+	return answer, nil
 }
 
-// GenerateSummary generates a mock summary
-func (s *MockAIService) GenerateSummary(text string) (string, []string, []string, error) {
+// GenerateSummary generates a mock summary with the same structure real providers return
+func (s *MockAIService) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
 	// Simulate API delay
-	time.Sleep(1 * time.Second)
+	if err := sleepContext(ctx, 1*time.Second); err != nil {
+		return nil, err
+	}
 
-	// Simple mock summary
 	wordCount := len(strings.Fields(text))
-	summary := fmt.Sprintf(`Summary:
-This document contains approximately %d words covering various topics.
-
-Key Takeaways:
-• This is a mock summary generated for development/testing purposes
-• Connect to a real AI service (Puter AI) for actual summaries
-• The document appears to contain structured information
-
-Main Topics:
-• Document Analysis
-• Information Extraction
-• Mock Data Processing`, wordCount)
-
-	takeaways := []string{
-		"This is a mock summary - connect to real AI for actual content",
-		"Document contains structured information",
-		"Approximately " + fmt.Sprintf("%d", wordCount) + " words processed",
+	result := &SummaryResult{
+		Summary: fmt.Sprintf("This document contains approximately %d words covering various topics. "+
+			"This is a mock summary generated for development/testing purposes; connect to a real AI service for actual summaries.", wordCount),
+		KeyTakeaways: []string{
+			"This is a mock summary - connect to real AI for actual content",
+			"Document contains structured information",
+			fmt.Sprintf("Approximately %d words processed", wordCount),
+		},
+		MainTopics: []string{
+			"Document Analysis",
+			"Information Extraction",
+			"Mock Processing",
+		},
+	}
+
+	// Reference the first page marker in the text, as a real provider would
+	if match := pageMarker.FindStringSubmatch(text); match != nil {
+		page, _ := strconv.Atoi(match[1])
+		result.PageReferences = []*proto.PageReference{{Page: int32(page), Point: "Start of the summarized text"}}
 	}
 
-	topics := []string{
-		"Document Analysis",
-		"Information Extraction",
-		"Mock Processing",
+	return withEmptyLists(result), nil
+}
+
+// CheckGrounding returns a verdict from the same keyword matching the mock answers with
+func (s *MockAIService) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	if strings.HasPrefix(answer, noAnswerReply) {
+		return Grounding{Confidence: 0.9, Reason: "None of the question's keywords appear in the document. [Mock verdict]"}, nil
 	}
 
-	return summary, takeaways, topics, nil
+	matches, keywords := mockKeywordMatches(docContext, strings.Fields(strings.ToLower(question)))
+	return Grounding{
+		AnswerFound: matches > 0,
+		Confidence:  float64(matches) / float64(max(keywords, 1)),
+		Reason:      fmt.Sprintf("%d of %d question keywords appear in the document. [Mock verdict]", matches, keywords),
+	}, nil
 }
 
+// RewriteQuery expands follow-up questions with terms from the conversation, without a model
+func (s *MockAIService) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	return HeuristicRewriteQuery(question, history), nil
+}
+
+// SummarizeHistory lists the questions asked, after the summary so far
+func (s *MockAIService) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	summary := strings.TrimSuffix(previous, " [Mock summary]")
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			summary = strings.TrimSpace(fmt.Sprintf("%s The user asked %q.", summary, strings.TrimSpace(msg.Content)))
+		}
+	}
+	return strings.TrimSpace(summary + " [Mock summary]"), nil
+}
+
+// sleepContext waits for the given duration or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+)
+
+// OllamaConfig configures a local Ollama server
+type OllamaConfig struct {
+	BaseURL        string // e.g. http://localhost:11434
+	Model          string // Chat model, e.g. llama3.1
+	EmbeddingModel string // Model for /api/embeddings, e.g. nomic-embed-text
+	KeepAlive      string // How long the server keeps models loaded, e.g. "5m" or "-1"; empty uses the server default
+	Temperature    float64
+	NumCtx         int           // Context window in tokens, sent with every request; default 8192
+	Timeout        time.Duration // Bounds non-streamed requests, and the wait for a stream to start
+}
+
+// OllamaService implements AIService and Embedder against Ollama's /api/chat
+// and /api/embeddings endpoints, for fully local inference
+type OllamaService struct {
+	config OllamaConfig
+	client *http.Client
+}
+
+// NewOllamaService creates a new Ollama service
+func NewOllamaService(config OllamaConfig) *OllamaService {
+	if config.BaseURL == "" {
+		config.BaseURL = "http://localhost:11434"
+	}
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	if config.Model == "" {
+		config.Model = "llama3.1"
+	}
+	if config.EmbeddingModel == "" {
+		config.EmbeddingModel = "nomic-embed-text"
+	}
+	if config.NumCtx == 0 {
+		// Ollama otherwise silently drops the start of prompts longer than its small default
+		config.NumCtx = 8192
+	}
+	if config.Timeout == 0 {
+		// Local models on modest hardware can take minutes to load and answer
+		config.Timeout = 5 * time.Minute
+	}
+
+	return &OllamaService{
+		config: config,
+		client: streamingClient(config.Timeout),
+	}
+}
+
+// ollamaChatRequest represents a request to /api/chat
+type ollamaChatRequest struct {
+	Model     string        `json:"model"`
+	Messages  []Message     `json:"messages"`
+	Stream    bool          `json:"stream"`
+	Format    string        `json:"format,omitempty"` // "json" constrains the reply to a JSON value
+	Options   ollamaOptions `json:"options"`
+	KeepAlive string        `json:"keep_alive,omitempty"`
+}
+
+// ollamaOptions are model parameters for a request
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumCtx      int     `json:"num_ctx,omitempty"`
+}
+
+// ollamaChatResponse is a /api/chat reply, or one line of a streamed reply
+type ollamaChatResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error"`
+}
+
+// ollamaEmbeddingRequest represents a request to /api/embeddings
+type ollamaEmbeddingRequest struct {
+	Model     string `json:"model"`
+	Prompt    string `json:"prompt"`
+	KeepAlive string `json:"keep_alive,omitempty"`
+}
+
+// ollamaEmbeddingResponse represents a response from /api/embeddings
+type ollamaEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+	Error     string    `json:"error"`
+}
+
+// ModelInfo implements AIService
+func (s *OllamaService) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: "ollama", Model: s.config.Model, ContextWindow: s.config.NumCtx}
+}
+
+// AnswerQuestion implements AIService
+func (s *OllamaService) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
+	answer, err := s.chat(ctx, questionMessages(docContext, question, history), "")
+	if err != nil {
+		return "", err
+	}
+
+	return answer, nil
+}
+
+// StreamAnswer implements AIService. Ollama streams newline-delimited JSON
+// objects rather than server-sent events.
+func (s *OllamaService) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	resp, err := s.post(ctx, "/api/chat", s.chatRequest(questionMessages(docContext, question, history), true, ""), true)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var answer strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return "", fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("AI error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			answer.WriteString(chunk.Message.Content)
+			if err := onToken(chunk.Message.Content); err != nil {
+				return "", err
+			}
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	if answer.Len() == 0 {
+		return "", fmt.Errorf("no response from AI")
+	}
+
+	return answer.String(), nil
+}
+
+// GenerateSummary implements AIService using Ollama's JSON mode
+func (s *OllamaService) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
+	return generateStructuredSummary(ctx, text, s.config.NumCtx, func(ctx context.Context, messages []Message) (string, error) {
+		return s.chat(ctx, messages, "json")
+	})
+}
+
+// CheckGrounding implements AIService using Ollama's JSON mode
+func (s *OllamaService) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	return checkGrounding(ctx, docContext, question, answer, func(ctx context.Context, messages []Message) (string, error) {
+		return s.chat(ctx, messages, "json")
+	})
+}
+
+// RewriteQuery implements AIService
+func (s *OllamaService) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	return rewriteQuery(ctx, question, history, func(ctx context.Context, messages []Message) (string, error) {
+		return s.chat(ctx, messages, "")
+	})
+}
+
+// SummarizeHistory implements AIService
+func (s *OllamaService) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	return summarizeHistory(ctx, previous, messages, func(ctx context.Context, messages []Message) (string, error) {
+		return s.chat(ctx, messages, "")
+	})
+}
+
+// Embed implements Embedder. /api/embeddings takes one text per request.
+func (s *OllamaService) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+
+	for _, text := range texts {
+		resp, err := s.post(ctx, "/api/embeddings", ollamaEmbeddingRequest{
+			Model:     s.config.EmbeddingModel,
+			Prompt:    text,
+			KeepAlive: s.config.KeepAlive,
+		}, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var embResp ollamaEmbeddingResponse
+		err = json.NewDecoder(resp.Body).Decode(&embResp)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse response: %w", err)
+		}
+		if embResp.Error != "" {
+			return nil, fmt.Errorf("embedding error: %s", embResp.Error)
+		}
+		if len(embResp.Embedding) == 0 {
+			return nil, fmt.Errorf("empty embedding returned")
+		}
+
+		vectors = append(vectors, embResp.Embedding)
+	}
+
+	return vectors, nil
+}
+
+// chat sends a non-streamed /api/chat request and returns the reply text
+func (s *OllamaService) chat(ctx context.Context, messages []Message, format string) (string, error) {
+	resp, err := s.post(ctx, "/api/chat", s.chatRequest(messages, false, format), false)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var chatResp ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if chatResp.Error != "" {
+		return "", fmt.Errorf("AI error: %s", chatResp.Error)
+	}
+	if chatResp.Message.Content == "" {
+		return "", fmt.Errorf("no response from AI")
+	}
+
+	return chatResp.Message.Content, nil
+}
+
+// chatRequest builds an /api/chat request with the configured model options
+func (s *OllamaService) chatRequest(messages []Message, stream bool, format string) ollamaChatRequest {
+	return ollamaChatRequest{
+		Model:    s.config.Model,
+		Messages: messages,
+		Stream:   stream,
+		Format:   format,
+		Options: ollamaOptions{
+			Temperature: s.config.Temperature,
+			NumCtx:      s.config.NumCtx,
+		},
+		KeepAlive: s.config.KeepAlive,
+	}
+}
+
+// post sends a JSON request to the Ollama server. The caller closes the body
+// of a successful response. Non-streamed requests are bounded by the
+// configured timeout; streams only by ctx once headers arrive.
+func (s *OllamaService) post(ctx context.Context, path string, body interface{}, stream bool) (resp *http.Response, err error) {
+	if !stream {
+		var done func(resp *http.Response, err error)
+		ctx, done = withTimeout(ctx, s.config.Timeout)
+		defer func() { done(resp, err) }()
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.config.BaseURL+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, chatapi.NewAPIError(resp, respBody)
+	}
+
+	return resp, nil
+}
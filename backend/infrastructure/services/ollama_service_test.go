@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newOllamaStandIn starts a local server that answers like Ollama and records
+// the chat requests it receives
+func newOllamaStandIn(t *testing.T, chatReply string) (*httptest.Server, *[]ollamaChatRequest) {
+	t.Helper()
+	var requests []ollamaChatRequest
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests = append(requests, req)
+
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"model \"missing\" not found, try pulling it first"}`)
+			return
+		}
+
+		if !req.Stream {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"model":   req.Model,
+				"message": map[string]string{"role": "assistant", "content": chatReply},
+				"done":    true,
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, word := range strings.SplitAfter(chatReply, " ") {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": map[string]string{"role": "assistant", "content": word},
+				"done":    false,
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": map[string]string{"content": ""}, "done": true})
+	})
+	mux.HandleFunc("/api/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaEmbeddingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Model != "embed-model" || req.KeepAlive != "10m" {
+			http.Error(w, "unexpected model or keep_alive", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"embedding": []float32{float32(len(req.Prompt)), 1, 0},
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestOllamaAnswerQuestion(t *testing.T) {
+	server, requests := newOllamaStandIn(t, "The contract ends in 2027.")
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL + "/", Model: "llama3.1", KeepAlive: "10m", NumCtx: 8192})
+
+	answer, err := ollama.AnswerQuestion(context.Background(), "The contract ends in 2027.", "When does it end?", nil)
+	if err != nil {
+		t.Fatalf("AnswerQuestion: %v", err)
+	}
+	if answer != "The contract ends in 2027." {
+		t.Errorf("got %q", answer)
+	}
+
+	if len(*requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*requests))
+	}
+	req := (*requests)[0]
+	if req.Model != "llama3.1" || req.Stream || req.KeepAlive != "10m" || req.Options.NumCtx != 8192 || req.Format != "" {
+		t.Errorf("unexpected request: %+v", req)
+	}
+	if len(req.Messages) != 2 || req.Messages[0].Role != "system" || !strings.Contains(req.Messages[1].Content, "When does it end?") {
+		t.Errorf("unexpected messages: %+v", req.Messages)
+	}
+}
+
+func TestOllamaStreamAnswer(t *testing.T) {
+	server, requests := newOllamaStandIn(t, "I cannot find this information in the document.")
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL})
+
+	var tokens []string
+	answer, err := ollama.StreamAnswer(context.Background(), "context", "question", nil, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAnswer: %v", err)
+	}
+	if answer != "I cannot find this information in the document." {
+		t.Errorf("got %q", answer)
+	}
+	if len(tokens) < 2 || strings.Join(tokens, "") != answer {
+		t.Errorf("tokens %q do not add up to the answer", tokens)
+	}
+	if !(*requests)[0].Stream || (*requests)[0].Model != "llama3.1" {
+		t.Errorf("expected a streamed request for the default model, got %+v", (*requests)[0])
+	}
+}
+
+func TestOllamaGenerateSummaryUsesJSONMode(t *testing.T) {
+	reply := `{"summary":"A lease agreement.","key_takeaways":["Rent is due monthly."],"main_topics":["Lease"],"entities":[],"page_references":[{"page":2,"point":"Rent terms"}]}`
+	server, requests := newOllamaStandIn(t, reply)
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL})
+
+	result, err := ollama.GenerateSummary(context.Background(), "[Page 2]\nRent is due monthly.")
+	if err != nil {
+		t.Fatalf("GenerateSummary: %v", err)
+	}
+	if result.Summary != "A lease agreement." || len(result.KeyTakeaways) != 1 || len(result.PageReferences) != 1 {
+		t.Errorf("unexpected summary: %+v", result)
+	}
+	if (*requests)[0].Format != "json" {
+		t.Errorf("format = %q, want json", (*requests)[0].Format)
+	}
+}
+
+func TestOllamaCheckGrounding(t *testing.T) {
+	server, requests := newOllamaStandIn(t, "```json\n{\"answer_found\": true, \"confidence\": 1.4, \"reason\": \"Stated in chunk 1.\",}\n```")
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL})
+
+	grounding, err := ollama.CheckGrounding(context.Background(), "[Chunk 1 - p. 2]\nParking is not available on site.", "Is there parking?", "No, parking is not available on site [1].")
+	if err != nil {
+		t.Fatalf("CheckGrounding: %v", err)
+	}
+	if !grounding.AnswerFound || grounding.Confidence != 1 || grounding.Reason != "Stated in chunk 1." {
+		t.Errorf("unexpected verdict: %+v", grounding)
+	}
+	if (*requests)[0].Format != "json" {
+		t.Errorf("format = %q, want json", (*requests)[0].Format)
+	}
+}
+
+func TestOllamaEmbed(t *testing.T) {
+	server, _ := newOllamaStandIn(t, "")
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL, EmbeddingModel: "embed-model", KeepAlive: "10m"})
+
+	vectors, err := ollama.Embed(context.Background(), []string{"one", "three"})
+	if err != nil {
+		t.Fatalf("Embed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 3 || vectors[1][0] != 5 {
+		t.Errorf("unexpected vectors: %v", vectors)
+	}
+}
+
+func TestOllamaErrorStatus(t *testing.T) {
+	server, _ := newOllamaStandIn(t, "")
+	ollama := NewOllamaService(OllamaConfig{BaseURL: server.URL, Model: "missing"})
+
+	_, err := ollama.AnswerQuestion(context.Background(), "context", "question", nil)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "try pulling it first") {
+		t.Errorf("expected the server error to be reported, got %v", err)
+	}
+}
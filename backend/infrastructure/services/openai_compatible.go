@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+)
+
+// OpenAICompatibleConfig configures an OpenAI-compatible chat completions API
+type OpenAICompatibleConfig struct {
+	Name          string // Provider name reported by ModelInfo, e.g. "vllm"
+	BaseURL       string // API root, e.g. http://localhost:8000/v1; may carry a query such as ?api-version=...
+	Model         string
+	APIKey        string // Optional
+	APIKeyHeader  string // Header carrying the key, e.g. "api-key" for Azure; default is a bearer token
+	Temperature   float64
+	MaxTokens     int               // 0 leaves the limit to the server
+	ContextWindow int               // Tokens the model accepts; default 8192
+	Headers       map[string]string // Extra headers sent with every request
+	JSONMode      bool              // Request response_format json_object for summaries
+	Timeout       time.Duration     // Bounds non-streamed requests, and the wait for a stream to start
+}
+
+// OpenAICompatibleService implements AIService against any server exposing
+// the OpenAI /chat/completions API: OpenAI itself, vLLM, llama.cpp, gateways and proxies
+type OpenAICompatibleService struct {
+	config   OpenAICompatibleConfig
+	endpoint string
+	client   *http.Client
+}
+
+// NewOpenAICompatibleService creates a new OpenAI-compatible service
+func NewOpenAICompatibleService(config OpenAICompatibleConfig) (*OpenAICompatibleService, error) {
+	if config.Model == "" {
+		return nil, fmt.Errorf("a model is required")
+	}
+
+	endpoint, err := url.Parse(config.BaseURL)
+	if err != nil || endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q", config.BaseURL)
+	}
+	endpoint.Path = strings.TrimSuffix(endpoint.Path, "/") + "/chat/completions"
+
+	if config.Name == "" {
+		config.Name = "openai"
+	}
+	if config.ContextWindow == 0 {
+		config.ContextWindow = 8192
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 60 * time.Second
+	}
+
+	return &OpenAICompatibleService{
+		config:   config,
+		endpoint: endpoint.String(),
+		client:   streamingClient(config.Timeout),
+	}, nil
+}
+
+// chatCompletionRequest represents an OpenAI-style chat completions request
+type chatCompletionRequest struct {
+	Model          string          `json:"model"`
+	Messages       []Message       `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	Stream         bool            `json:"stream"`
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// chatCompletionResponse represents an OpenAI-style chat completions response
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// answerSystemPrompt instructs the model to answer only from the document context
+const answerSystemPrompt = "You are a helpful assistant that answers questions based ONLY on the provided document context. If the answer is not in the context, say 'I cannot find this information in the document.'"
+
+// ModelInfo implements AIService
+func (s *OpenAICompatibleService) ModelInfo() ModelInfo {
+	return ModelInfo{Provider: s.config.Name, Model: s.config.Model, ContextWindow: s.config.ContextWindow}
+}
+
+// AnswerQuestion implements AIService
+func (s *OpenAICompatibleService) AnswerQuestion(ctx context.Context, docContext string, question string, history []ChatMessage) (string, error) {
+	answer, err := s.complete(ctx, questionMessages(docContext, question, history), nil)
+	if err != nil {
+		return "", err
+	}
+
+	return answer, nil
+}
+
+// StreamAnswer implements AIService
+func (s *OpenAICompatibleService) StreamAnswer(ctx context.Context, docContext string, question string, history []ChatMessage, onToken TokenHandler) (string, error) {
+	resp, err := s.send(ctx, chatCompletionRequest{
+		Model:       s.config.Model,
+		Messages:    questionMessages(docContext, question, history),
+		Temperature: s.config.Temperature,
+		MaxTokens:   s.config.MaxTokens,
+		Stream:      true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	answer, err := chatapi.ReadStream(resp.Body, onToken)
+	if err != nil {
+		return "", err
+	}
+
+	if answer == "" {
+		return "", fmt.Errorf("no response from AI")
+	}
+
+	return answer, nil
+}
+
+// GenerateSummary implements AIService
+func (s *OpenAICompatibleService) GenerateSummary(ctx context.Context, text string) (*SummaryResult, error) {
+	var format *ResponseFormat
+	if s.config.JSONMode {
+		format = &ResponseFormat{Type: "json_object"}
+	}
+
+	return generateStructuredSummary(ctx, text, s.config.ContextWindow, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, format)
+	})
+}
+
+// CheckGrounding implements AIService
+func (s *OpenAICompatibleService) CheckGrounding(ctx context.Context, docContext string, question string, answer string) (Grounding, error) {
+	var format *ResponseFormat
+	if s.config.JSONMode {
+		format = &ResponseFormat{Type: "json_object"}
+	}
+
+	return checkGrounding(ctx, docContext, question, answer, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, format)
+	})
+}
+
+// RewriteQuery implements AIService
+func (s *OpenAICompatibleService) RewriteQuery(ctx context.Context, question string, history []ChatMessage) (string, error) {
+	return rewriteQuery(ctx, question, history, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, nil)
+	})
+}
+
+// SummarizeHistory implements AIService
+func (s *OpenAICompatibleService) SummarizeHistory(ctx context.Context, previous string, messages []ChatMessage) (string, error) {
+	return summarizeHistory(ctx, previous, messages, func(ctx context.Context, messages []Message) (string, error) {
+		return s.complete(ctx, messages, nil)
+	})
+}
+
+// complete sends a non-streamed request and returns the reply text
+func (s *OpenAICompatibleService) complete(ctx context.Context, messages []Message, format *ResponseFormat) (string, error) {
+	resp, err := s.send(ctx, chatCompletionRequest{
+		Model:          s.config.Model,
+		Messages:       messages,
+		Temperature:    s.config.Temperature,
+		MaxTokens:      s.config.MaxTokens,
+		ResponseFormat: format,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	if completion.Error != nil {
+		return "", fmt.Errorf("AI error: %s", completion.Error.Message)
+	}
+
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("no response from AI")
+	}
+
+	return completion.Choices[0].Message.Content, nil
+}
+
+// send posts a request with the configured headers. The caller closes the
+// body of a successful response. Non-streamed requests are bounded by the
+// configured timeout; streams only by ctx once headers arrive.
+func (s *OpenAICompatibleService) send(ctx context.Context, reqBody chatCompletionRequest) (resp *http.Response, err error) {
+	if !reqBody.Stream {
+		var done func(resp *http.Response, err error)
+		ctx, done = withTimeout(ctx, s.config.Timeout)
+		defer func() { done(resp, err) }()
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if reqBody.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if s.config.APIKey != "" {
+		if s.config.APIKeyHeader != "" {
+			req.Header.Set(s.config.APIKeyHeader, s.config.APIKey)
+		} else {
+			req.Header.Set("Authorization", "Bearer "+s.config.APIKey)
+		}
+	}
+	for name, value := range s.config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err = s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, chatapi.NewAPIError(resp, body)
+	}
+
+	return resp, nil
+}
+
+// questionMessages builds the chat messages for answering a question: the
+// instructions, the conversation so far with its roles, then the question with
+// its document context
+func questionMessages(docContext string, question string, history []ChatMessage) []Message {
+	messages := make([]Message, 0, len(history)+2)
+	messages = append(messages, Message{Role: "system", Content: answerSystemPrompt})
+	for _, msg := range history {
+		messages = append(messages, Message(msg))
+	}
+	messages = append(messages, Message{Role: "user", Content: buildQuestionPrompt(docContext, question)})
+
+	return messages
+}
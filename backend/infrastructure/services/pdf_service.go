@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
+	"unicode"
 
 	"ai-pdf-assistant-backend/proto"
 	"github.com/google/uuid"
@@ -12,17 +14,22 @@ import (
 
 // PDFService handles PDF parsing and text extraction
 type PDFService struct {
-	uploadDir string
+	uploadDir   string
+	denseSearch *DenseSearch // Optional; fills chunk embeddings when set
 }
 
 // NewPDFService creates a new PDF service
-func NewPDFService(uploadDir string) *PDFService {
+func NewPDFService(uploadDir string, denseSearch *DenseSearch) *PDFService {
 	os.MkdirAll(uploadDir, 0755)
-	return &PDFService{uploadDir: uploadDir}
+	return &PDFService{uploadDir: uploadDir, denseSearch: denseSearch}
 }
 
-// ProcessPDF extracts text from a PDF file and creates chunks
-func (s *PDFService) ProcessPDF(filePath string, filename string) (*proto.Document, error) {
+// ProgressFunc is called as pages are extracted from a PDF
+type ProgressFunc func(pagesDone int, pagesTotal int)
+
+// ProcessPDF extracts text from a PDF file and creates chunks.
+// onProgress may be nil.
+func (s *PDFService) ProcessPDF(ctx context.Context, documentID string, filePath string, filename string, onProgress ProgressFunc) (*proto.Document, error) {
 	// Open the PDF file
 	file, reader, err := pdf.Open(filePath)
 	if err != nil {
@@ -31,10 +38,18 @@ func (s *PDFService) ProcessPDF(filePath string, filename string) (*proto.Docume
 	defer file.Close()
 
 	var textBuilder strings.Builder
+	var pages []pageText
 	totalPages := reader.NumPage()
 
-	// Extract text from all pages
+	// Extract text page by page so chunks can keep track of where they came from
 	for pageNum := 1; pageNum <= totalPages; pageNum++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if onProgress != nil {
+			onProgress(pageNum-1, totalPages)
+		}
+
 		page := reader.Page(pageNum)
 		if page.V.IsNull() {
 			continue
@@ -45,9 +60,13 @@ func (s *PDFService) ProcessPDF(filePath string, filename string) (*proto.Docume
 			continue // Skip pages with extraction errors
 		}
 
+		pages = append(pages, pageText{number: pageNum, text: text})
 		textBuilder.WriteString(text)
 		textBuilder.WriteString("\n\n")
 	}
+	if onProgress != nil {
+		onProgress(totalPages, totalPages)
+	}
 
 	extractedText := textBuilder.String()
 	if strings.TrimSpace(extractedText) == "" {
@@ -55,21 +74,34 @@ func (s *PDFService) ProcessPDF(filePath string, filename string) (*proto.Docume
 	}
 
 	// Create chunks
-	chunks := s.chunkText(extractedText, 2000) // 2000 character chunks
+	chunks := s.chunkPages(pages, 2000) // 2000 character chunks
 	protoChunks := make([]*proto.Chunk, len(chunks))
 
-	for i, chunkText := range chunks {
+	for i, chunk := range chunks {
 		protoChunks[i] = &proto.Chunk{
-			Id:         uuid.New().String(),
-			Text:       chunkText,
-			ChunkIndex: int32(i),
-			PageNumber: 1, // Simplified - could track actual page
+			Id:            uuid.New().String(),
+			Text:          chunk.text,
+			ChunkIndex:    int32(i),
+			PageNumber:    int32(chunk.startPage),
+			EndPageNumber: int32(chunk.endPage),
+			StartOffset:   int32(chunk.startOffset),
+			EndOffset:     int32(chunk.endOffset),
+		}
+	}
+
+	// Embed chunks for semantic search; keyword search still works without them
+	if s.denseSearch != nil {
+		if err := s.denseSearch.EmbedChunks(ctx, protoChunks); err != nil {
+			fmt.Printf("Warning: Failed to embed chunks for %s: %v\n", filename, err)
 		}
 	}
 
 	// Create document
+	if documentID == "" {
+		documentID = uuid.New().String()
+	}
 	doc := &proto.Document{
-		Id:       uuid.New().String(),
+		Id:       documentID,
 		Filename: filename,
 		Text:     extractedText,
 		Pages:    int32(totalPages),
@@ -79,35 +111,91 @@ func (s *PDFService) ProcessPDF(filePath string, filename string) (*proto.Docume
 	return doc, nil
 }
 
-// chunkText splits text into chunks of approximately maxChunkSize characters
-func (s *PDFService) chunkText(text string, maxChunkSize int) []string {
-	if len(text) <= maxChunkSize {
-		return []string{text}
-	}
+// pageText holds the extracted text of a single PDF page
+type pageText struct {
+	number int
+	text   string
+}
 
-	var chunks []string
-	words := strings.Fields(text)
+// textChunk is a piece of document text along with the pages it spans
+type textChunk struct {
+	text        string
+	startPage   int
+	endPage     int
+	startOffset int // Character offset of the first word in the start page's text
+	endOffset   int // Character offset just past the last word in the end page's text
+}
 
+// chunkPages splits page texts into chunks of approximately maxChunkSize characters.
+// Chunks may cross page boundaries; each chunk records its first and last page.
+func (s *PDFService) chunkPages(pages []pageText, maxChunkSize int) []textChunk {
+	var chunks []textChunk
 	var currentChunk strings.Builder
+	startPage, endPage := 0, 0
+	startOffset, endOffset := 0, 0
 
-	for _, word := range words {
-		// Check if adding this word would exceed the limit
-		if currentChunk.Len()+len(word)+1 > maxChunkSize && currentChunk.Len() > 0 {
-			chunks = append(chunks, strings.TrimSpace(currentChunk.String()))
-			currentChunk.Reset()
+	flush := func() {
+		if currentChunk.Len() == 0 {
+			return
 		}
+		chunks = append(chunks, textChunk{
+			text:        strings.TrimSpace(currentChunk.String()),
+			startPage:   startPage,
+			endPage:     endPage,
+			startOffset: startOffset,
+			endOffset:   endOffset,
+		})
+		currentChunk.Reset()
+	}
 
-		if currentChunk.Len() > 0 {
-			currentChunk.WriteString(" ")
+	for _, page := range pages {
+		for _, word := range pageWords(page.text) {
+			// Check if adding this word would exceed the limit
+			if currentChunk.Len()+len(word.text)+1 > maxChunkSize && currentChunk.Len() > 0 {
+				flush()
+			}
+
+			if currentChunk.Len() == 0 {
+				startPage, startOffset = page.number, word.start
+			} else {
+				currentChunk.WriteString(" ")
+			}
+			currentChunk.WriteString(word.text)
+			endPage, endOffset = page.number, word.end
 		}
-		currentChunk.WriteString(word)
 	}
 
 	// Add the last chunk if it has content
-	if currentChunk.Len() > 0 {
-		chunks = append(chunks, strings.TrimSpace(currentChunk.String()))
-	}
+	flush()
 
 	return chunks
 }
 
+// pageWord is a whitespace-separated word with its character offsets in the page text
+type pageWord struct {
+	text       string
+	start, end int
+}
+
+// pageWords splits page text like strings.Fields, keeping each word's character
+// (not byte) offsets so a viewer can find the passage in the page's text layer
+func pageWords(text string) []pageWord {
+	var words []pageWord
+	wordStart, wordByte := -1, 0
+	offset := 0
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if wordStart >= 0 {
+				words = append(words, pageWord{text: text[wordByte:i], start: wordStart, end: offset})
+				wordStart = -1
+			}
+		} else if wordStart < 0 {
+			wordStart, wordByte = offset, i
+		}
+		offset++
+	}
+	if wordStart >= 0 {
+		words = append(words, pageWord{text: text[wordByte:], start: wordStart, end: offset})
+	}
+	return words
+}
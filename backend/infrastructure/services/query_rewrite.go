@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+)
+
+// rewriteSystemPrompt asks for a standalone search query
+const rewriteSystemPrompt = `You rewrite follow-up questions into standalone search queries for finding passages in the user's documents. Use the conversation to resolve references such as "it", "that section" or "the second one", and keep names, numbers and key terms. Reply with the search query only, on one line. If the question already stands on its own, repeat it unchanged.`
+
+// rewriteHistoryMessages is how many recent messages are shown when rewriting a query
+const rewriteHistoryMessages = 6
+
+// rewriteQuery asks a provider to turn a follow-up question into a standalone search query
+func rewriteQuery(ctx context.Context, question string, history []ChatMessage, complete completeFunc) (string, error) {
+	if len(history) == 0 {
+		return question, nil
+	}
+
+	reply, err := complete(ctx, []Message{
+		{Role: "system", Content: rewriteSystemPrompt},
+		{Role: "user", Content: buildRewritePrompt(question, history)},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if query := cleanQuery(reply); query != "" {
+		return query, nil
+	}
+	return question, nil
+}
+
+// buildRewritePrompt shows the recent conversation and the follow-up question
+func buildRewritePrompt(question string, history []ChatMessage) string {
+	var builder strings.Builder
+	builder.WriteString("Conversation:\n")
+	for _, msg := range history[max(len(history)-rewriteHistoryMessages, 0):] {
+		content, cut := tokenizer.Truncate(msg.Content, 300)
+		if cut {
+			content += " ..."
+		}
+		fmt.Fprintf(&builder, "%s: %s\n", speaker(msg.Role), content)
+	}
+	fmt.Fprintf(&builder, "\nFollow-up question: %s\n\nStandalone search query:", question)
+
+	return builder.String()
+}
+
+// cleanQuery takes the first line of a reply, without labels or quotes
+func cleanQuery(reply string) string {
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		for _, label := range []string{"Standalone search query:", "Search query:", "Query:"} {
+			if len(line) >= len(label) && strings.EqualFold(line[:len(label)], label) {
+				line = strings.TrimSpace(line[len(label):])
+			}
+		}
+		line = strings.Trim(line, "\"'`")
+		if line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// speaker names the author of a message in a transcript
+func speaker(role string) string {
+	switch role {
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "Summary"
+	default:
+		return "User"
+	}
+}
+
+// rewriteExpansionTerms is how many terms of the previous question the heuristic rewrite adds
+const rewriteExpansionTerms = 12
+
+// followUpWords mark a question that leans on the conversation for its meaning
+var followUpWords = map[string]bool{
+	"it": true, "its": true, "this": true, "that": true, "these": true, "those": true,
+	"they": true, "them": true, "their": true, "he": true, "she": true, "him": true,
+	"her": true, "one": true, "ones": true, "first": true, "second": true, "third": true,
+	"last": true, "former": true, "latter": true, "same": true, "above": true,
+	"previous": true, "else": true, "more": true, "also": true,
+}
+
+// HeuristicRewriteQuery expands a follow-up question with the key terms of the
+// previous question. A question is a follow-up when it uses one of
+// followUpWords; other questions are returned unchanged. It needs no model, so
+// it serves the mock provider and any provider whose rewrite fails.
+func HeuristicRewriteQuery(question string, history []ChatMessage) string {
+	words := strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	followUp := false
+	for _, word := range words {
+		if followUpWords[word] {
+			followUp = true
+		}
+	}
+	if !followUp {
+		return question
+	}
+
+	// Answers are left out: their wording would pull the search toward the
+	// passages already used rather than the ones the question is about
+	var lastQuestion string
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			lastQuestion = citationMarker.ReplaceAllString(history[i].Content, " ")
+			break
+		}
+	}
+	if lastQuestion == "" {
+		return question
+	}
+
+	seen := make(map[string]bool)
+	for _, word := range words {
+		seen[word] = true
+	}
+	terms := []string{strings.TrimSpace(question)}
+	for _, term := range NewTokenizer(false).Tokenize(lastQuestion) {
+		if len(terms) > rewriteExpansionTerms {
+			break
+		}
+		if !seen[term] && !followUpWords[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	return strings.Join(terms, " ")
+}
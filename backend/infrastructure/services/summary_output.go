@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+	"ai-pdf-assistant-backend/proto"
+)
+
+// SummaryResult is the structured summary produced by an AI provider
+type SummaryResult struct {
+	Summary        string                 `json:"summary"`
+	KeyTakeaways   []string               `json:"key_takeaways"`
+	MainTopics     []string               `json:"main_topics"`
+	Entities       []*proto.Entity        `json:"entities"`
+	PageReferences []*proto.PageReference `json:"page_references"`
+}
+
+// SummaryPromptVersion identifies the summary prompts; bump it whenever they
+// change so cached summaries are regenerated
+const SummaryPromptVersion = "2"
+
+// summarySystemPrompt asks for the JSON object parseSummaryJSON accepts
+const summarySystemPrompt = `You are an assistant that summarizes documents. Respond with a single JSON object and nothing else, using exactly this schema:
+{
+  "summary": "2-4 paragraph overview of the document",
+  "key_takeaways": ["complete sentence stating one key point", "..."],
+  "main_topics": ["short noun phrase naming a theme", "..."],
+  "entities": [{"name": "Acme Corp", "type": "organization"}],
+  "page_references": [{"page": 3, "point": "what that page supports"}]
+}
+Give 3-7 key takeaways and 3-6 main topics. Entity types are person, organization, location, date or concept. Only cite page numbers that appear in [Page N] or [Pages N-M] markers in the text; use an empty list if there are none.`
+
+// SummaryAnswerTokens is the room kept in the context window for a summary reply
+const SummaryAnswerTokens = 1500
+
+// SummaryTextTokens returns how much document text fits in one summary prompt
+// for a model with the given context window. Room is left for the reply and
+// for a re-request that repeats it.
+func SummaryTextTokens(contextWindow int) int {
+	overhead := tokenizer.Count(summarySystemPrompt) + tokenizer.Count(buildSummaryPrompt("", 0)) + 100
+	return max(contextWindow-overhead-2*SummaryAnswerTokens, 0)
+}
+
+// completeFunc sends chat messages to a provider and returns the reply text
+type completeFunc func(ctx context.Context, messages []Message) (string, error)
+
+// generateStructuredSummary asks the provider for a JSON summary. A malformed
+// reply is repaired where possible, otherwise re-requested once; scraping the
+// text for bullet points is the last resort.
+func generateStructuredSummary(ctx context.Context, text string, contextWindow int, complete completeFunc) (*SummaryResult, error) {
+	messages := []Message{
+		{Role: "system", Content: summarySystemPrompt},
+		{Role: "user", Content: buildSummaryPrompt(text, SummaryTextTokens(contextWindow))},
+	}
+
+	reply, err := complete(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+	result, parseErr := parseSummaryJSON(reply)
+	if parseErr == nil {
+		return result, nil
+	}
+
+	// Show the model its reply and what was wrong with it
+	messages = append(messages,
+		Message{Role: "assistant", Content: reply},
+		Message{Role: "user", Content: fmt.Sprintf("That reply could not be used: %v. Respond again with only the JSON object in the required schema.", parseErr)},
+	)
+	retry, err := complete(ctx, messages)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		fmt.Printf("Warning: Summary re-request failed: %v\n", err)
+	} else {
+		if result, err := parseSummaryJSON(retry); err == nil {
+			return result, nil
+		}
+		reply = retry
+	}
+
+	fmt.Printf("Warning: Summary was not valid JSON, extracting from text: %v\n", parseErr)
+	return summaryFromText(reply), nil
+}
+
+// trailingCommas matches commas left before a closing bracket, a common model mistake
+var trailingCommas = regexp.MustCompile(`,\s*([}\]])`)
+
+// parseSummaryJSON extracts and validates a SummaryResult from a model reply,
+// tolerating code fences, surrounding prose and trailing commas
+func parseSummaryJSON(reply string) (*SummaryResult, error) {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON object found")
+	}
+	raw := reply[start : end+1]
+
+	var result SummaryResult
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		result = SummaryResult{}
+		repaired := trailingCommas.ReplaceAllString(raw, "$1")
+		if json.Unmarshal([]byte(repaired), &result) != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	result.Summary = strings.TrimSpace(result.Summary)
+	result.KeyTakeaways = cleanList(result.KeyTakeaways)
+	result.MainTopics = cleanList(result.MainTopics)
+
+	var entities []*proto.Entity
+	seen := make(map[string]bool)
+	for _, entity := range result.Entities {
+		if entity == nil {
+			continue
+		}
+		entity.Name = strings.TrimSpace(entity.Name)
+		entity.Type = strings.ToLower(strings.TrimSpace(entity.Type))
+		key := strings.ToLower(entity.Name)
+		if entity.Name != "" && !seen[key] {
+			seen[key] = true
+			entities = append(entities, entity)
+		}
+	}
+	result.Entities = entities
+
+	var references []*proto.PageReference
+	for _, ref := range result.PageReferences {
+		if ref != nil && ref.Page > 0 {
+			ref.Point = strings.TrimSpace(ref.Point)
+			references = append(references, ref)
+		}
+	}
+	result.PageReferences = references
+
+	if result.Summary == "" {
+		return nil, fmt.Errorf(`"summary" is missing or empty`)
+	}
+	if len(result.KeyTakeaways) == 0 {
+		return nil, fmt.Errorf(`"key_takeaways" is missing or empty`)
+	}
+
+	return withEmptyLists(&result), nil
+}
+
+// summaryFromText builds a SummaryResult from a free-text reply
+func summaryFromText(text string) *SummaryResult {
+	return withEmptyLists(&SummaryResult{
+		Summary:      strings.TrimSpace(text),
+		KeyTakeaways: extractTakeaways(text),
+		MainTopics:   extractTopics(text),
+	})
+}
+
+// withEmptyLists replaces missing lists with empty ones, so they encode as [] rather than null
+func withEmptyLists(result *SummaryResult) *SummaryResult {
+	if result.KeyTakeaways == nil {
+		result.KeyTakeaways = []string{}
+	}
+	if result.MainTopics == nil {
+		result.MainTopics = []string{}
+	}
+	if result.Entities == nil {
+		result.Entities = []*proto.Entity{}
+	}
+	if result.PageReferences == nil {
+		result.PageReferences = []*proto.PageReference{}
+	}
+	return result
+}
+
+// cleanList trims items and drops blanks and case-insensitive duplicates
+func cleanList(items []string) []string {
+	var cleaned []string
+	seen := make(map[string]bool)
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		key := strings.ToLower(item)
+		if item != "" && !seen[key] {
+			seen[key] = true
+			cleaned = append(cleaned, item)
+		}
+	}
+	return cleaned
+}
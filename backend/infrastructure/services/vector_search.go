@@ -5,72 +5,69 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
-// VectorSearch provides simple text-based similarity search
-// For MVP, we use keyword matching instead of real embeddings
+// VectorSearch ranks document chunks against a query.
+// Each document gets a BM25 inverted index built once at ingest time.
 type VectorSearch struct {
+	tokenizer *Tokenizer
+	indexes   map[string]*BM25Index // document ID -> index
+	chunkDocs map[string]string     // chunk ID -> document ID
+	mutex     sync.RWMutex
 }
 
 // NewVectorSearch creates a new vector search service
-func NewVectorSearch() *VectorSearch {
-	return &VectorSearch{}
+func NewVectorSearch(stemming bool) *VectorSearch {
+	return &VectorSearch{
+		tokenizer: NewTokenizer(stemming),
+		indexes:   make(map[string]*BM25Index),
+		chunkDocs: make(map[string]string),
+	}
 }
 
-// FindRelevantChunks finds chunks most relevant to the query
-// Uses simple keyword matching for MVP (can be upgraded to real embeddings later)
-func (v *VectorSearch) FindRelevantChunks(chunks []*proto.Chunk, query string, topK int) []*proto.Chunk {
-	if len(chunks) == 0 {
-		return []*proto.Chunk{}
-	}
+// IndexDocument builds the BM25 index for a document's chunks
+func (v *VectorSearch) IndexDocument(doc *proto.Document) {
+	idx := NewBM25Index(doc.Chunks, v.tokenizer)
 
-	queryLower := strings.ToLower(query)
-	queryWords := strings.Fields(queryLower)
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 
-	// Score each chunk based on keyword matches
-	type scoredChunk struct {
-		chunk *proto.Chunk
-		score int
+	v.indexes[doc.Id] = idx
+	for _, chunk := range doc.Chunks {
+		v.chunkDocs[chunk.Id] = doc.Id
 	}
+}
 
-	scored := make([]scoredChunk, 0, len(chunks))
-	for _, chunk := range chunks {
-		chunkLower := strings.ToLower(chunk.Text)
-		score := 0
-
-		// Count keyword matches
-		for _, word := range queryWords {
-			if strings.Contains(chunkLower, word) {
-				score++
-			}
-		}
+// RemoveDocument drops the index for a document
+func (v *VectorSearch) RemoveDocument(documentID string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
 
-		// Bonus for exact phrase match
-		if strings.Contains(chunkLower, queryLower) {
-			score += 5
+	delete(v.indexes, documentID)
+	for chunkID, docID := range v.chunkDocs {
+		if docID == documentID {
+			delete(v.chunkDocs, chunkID)
 		}
-
-		scored = append(scored, scoredChunk{chunk: chunk, score: score})
 	}
+}
 
-	// Sort by score descending so best matches come first
-	sort.Slice(scored, func(i, j int) bool {
-		return scored[i].score > scored[j].score
-	})
+// FindRelevantChunks finds the topK chunks most relevant to the query using BM25
+func (v *VectorSearch) FindRelevantChunks(chunks []*proto.Chunk, query string, topK int) []*proto.Chunk {
+	if len(chunks) == 0 {
+		return []*proto.Chunk{}
+	}
 
-	// Return top K chunks with matches
-	relevant := make([]*proto.Chunk, 0, topK)
-	for _, s := range scored {
-		if s.score > 0 {
-			relevant = append(relevant, s.chunk)
-			if len(relevant) >= topK {
-				break
-			}
-		}
+	ranked := v.RankChunks(chunks, query, topK)
+	relevant := make([]*proto.Chunk, len(ranked))
+	for i, s := range ranked {
+		relevant[i] = s.Chunk
 	}
 
 	// If no matches, return first few chunks as fallback
-	if len(relevant) == 0 && len(chunks) > 0 {
+	if len(relevant) == 0 {
 		maxReturn := topK
 		if len(chunks) < maxReturn {
 			maxReturn = len(chunks)
@@ -81,6 +78,51 @@ func (v *VectorSearch) FindRelevantChunks(chunks []*proto.Chunk, query string, t
 	return relevant
 }
 
+// RankChunks returns up to topK chunks matching the query with their BM25 scores
+func (v *VectorSearch) RankChunks(chunks []*proto.Chunk, query string, topK int) []ScoredChunk {
+	scores := v.scoreChunks(chunks, query)
+
+	scored := make([]ScoredChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		if score := scores[chunk.Id]; score > 0 {
+			scored = append(scored, ScoredChunk{Chunk: chunk, Score: score, Retriever: RetrieverKeyword})
+		}
+	}
+
+	return topScored(scored, topK)
+}
+
+// scoreChunks scores chunks with their document's index. Chunks that were
+// never indexed are scored with a temporary index built over just them.
+func (v *VectorSearch) scoreChunks(chunks []*proto.Chunk, query string) map[string]float64 {
+	v.mutex.RLock()
+	indexes := make(map[string]*BM25Index)
+	var unindexed []*proto.Chunk
+	for _, chunk := range chunks {
+		docID, ok := v.chunkDocs[chunk.Id]
+		if !ok {
+			unindexed = append(unindexed, chunk)
+			continue
+		}
+		indexes[docID] = v.indexes[docID]
+	}
+	v.mutex.RUnlock()
+
+	scores := make(map[string]float64)
+	for _, idx := range indexes {
+		for chunkID, score := range idx.Score(query) {
+			scores[chunkID] = score
+		}
+	}
+	if len(unindexed) > 0 {
+		for chunkID, score := range NewBM25Index(unindexed, v.tokenizer).Score(query) {
+			scores[chunkID] = score
+		}
+	}
+
+	return scores
+}
+
 // BuildContext builds a context string from relevant chunks
 func (v *VectorSearch) BuildContext(chunks []*proto.Chunk) string {
 	if len(chunks) == 0 {
@@ -88,14 +130,10 @@ func (v *VectorSearch) BuildContext(chunks []*proto.Chunk) string {
 	}
 
 	var builder strings.Builder
-	builder.WriteString("Document Context:\n\n")
+	builder.WriteString(contextHeader)
 
 	for i, chunk := range chunks {
-		pageNum := chunk.PageNumber
-		if pageNum == 0 {
-			pageNum = 1 // Default to page 1 if not set
-		}
-		builder.WriteString(fmt.Sprintf("[Chunk %d - Page %d]\n", i+1, pageNum))
+		builder.WriteString(chunkHeader(i, chunk))
 		builder.WriteString(chunk.Text)
 		builder.WriteString("\n\n")
 	}
@@ -103,40 +141,208 @@ func (v *VectorSearch) BuildContext(chunks []*proto.Chunk) string {
 	return builder.String()
 }
 
-// Citation represents a page reference for a chunk
-type Citation struct {
-	Page int32  `json:"page"`
-	Text string `json:"text"`
+// contextHeader starts the document context given to the model
+const contextHeader = "Document Context:\n\n"
+
+// chunkHeader labels the i-th chunk of the document context with its pages
+func chunkHeader(i int, chunk *proto.Chunk) string {
+	return fmt.Sprintf("[Chunk %d - %s]\n", i+1, pageLabel(chunkPages(chunk)))
 }
 
-// GetCitations extracts unique page citations from chunks
-func (v *VectorSearch) GetCitations(chunks []*proto.Chunk) []Citation {
+// snippetChars is the length of a citation snippet, in characters
+const snippetChars = 240
+
+// GetCitations describes where each chunk comes from: its document, pages and
+// offsets, with a snippet around the words of the query. Each citation's marker
+// is the chunk's number in BuildContext for the same chunks. documents are the
+// session's documents, in upload order; citations follow that order.
+func (v *VectorSearch) GetCitations(chunks []*proto.Chunk, documents []*proto.Document, query string) []*proto.Citation {
 	if len(chunks) == 0 {
-		return []Citation{}
+		return []*proto.Citation{}
 	}
 
-	// Use a map to collect unique pages with sample text
-	pageMap := make(map[int32]string)
-	for _, chunk := range chunks {
-		pageNum := chunk.PageNumber
-		if pageNum == 0 {
-			pageNum = 1
-		}
-		if _, exists := pageMap[pageNum]; !exists {
-			// Store a preview of the text (max 100 chars)
-			text := chunk.Text
-			if len(text) > 100 {
-				text = text[:100] + "..."
-			}
-			pageMap[pageNum] = text
+	// Locate each chunk in its document; chunks cut to fit the prompt keep their ID
+	type location struct {
+		doc      *proto.Document
+		order    int
+		original *proto.Chunk
+	}
+	locations := make(map[string]location)
+	for i, doc := range documents {
+		for _, chunk := range doc.Chunks {
+			locations[chunk.Id] = location{doc: doc, order: i, original: chunk}
 		}
 	}
 
-	// Convert map to slice
-	citations := make([]Citation, 0, len(pageMap))
-	for page, text := range pageMap {
-		citations = append(citations, Citation{Page: page, Text: text})
+	terms := make(map[string]bool)
+	for _, term := range v.tokenizer.Tokenize(query) {
+		terms[term] = true
 	}
 
+	seen := make(map[string]bool)
+	citations := make([]*proto.Citation, 0, len(chunks))
+	order := make(map[*proto.Citation]int)
+	for i, chunk := range chunks {
+		if seen[chunk.Id] {
+			continue
+		}
+		seen[chunk.Id] = true
+
+		loc, ok := locations[chunk.Id]
+		if !ok {
+			loc = location{order: len(documents), original: chunk}
+		}
+		start, end := chunkPages(loc.original)
+		snippet, highlights := v.snippet(chunk.Text, terms)
+
+		citation := &proto.Citation{
+			ChunkId:     chunk.Id,
+			Page:        start,
+			EndPage:     end,
+			Label:       pageLabel(start, end),
+			StartOffset: loc.original.StartOffset,
+			EndOffset:   loc.original.EndOffset,
+			Snippet:     snippet,
+			Highlights:  highlights,
+			Marker:      int32(i + 1),
+		}
+		if loc.doc != nil {
+			citation.DocumentId = loc.doc.Id
+			citation.Filename = loc.doc.Filename
+		}
+		citations = append(citations, citation)
+		order[citation] = loc.order
+	}
+
+	// Order citations as they appear in the documents
+	sort.SliceStable(citations, func(i, j int) bool {
+		a, b := citations[i], citations[j]
+		if order[a] != order[b] {
+			return order[a] < order[b]
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.StartOffset < b.StartOffset
+	})
+
 	return citations
 }
+
+// snippet cuts the window of text with the most query terms, at word
+// boundaries, and marks the words that match
+func (v *VectorSearch) snippet(text string, terms map[string]bool) (string, []*proto.Highlight) {
+	words := pageWords(text)
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	matches := make([]bool, len(words))
+	for i, word := range words {
+		for _, token := range v.tokenizer.Tokenize(word.text) {
+			if terms[token] {
+				matches[i] = true
+				break
+			}
+		}
+	}
+
+	// Slide a window of whole words over the text, keeping the one with the most matches
+	first, best, count, last := 0, -1, 0, 0
+	for i := range words {
+		if last < i {
+			last, count = i, 0 // The previous word was longer than a snippet
+		}
+		for last < len(words) && words[last].end-words[i].start <= snippetChars {
+			if matches[last] {
+				count++
+			}
+			last++
+		}
+		if count > best {
+			first, best = i, count
+		}
+		if last > i && matches[i] {
+			count--
+		}
+	}
+
+	// Lead into the first match with some of the text before it
+	if best > 0 {
+		match := first
+		for !matches[match] {
+			match++
+		}
+		for first = match; first > 0 && words[match].start-words[first-1].start <= snippetChars/3; first-- {
+		}
+	}
+
+	runes := []rune(text)
+	from := words[first].start
+	to := from
+	for _, word := range words[first:] {
+		if word.end-from > snippetChars {
+			break
+		}
+		to = word.end
+	}
+	if to == from {
+		to = min(from+snippetChars, len(runes)) // A single word longer than the snippet
+	}
+
+	prefix := ""
+	if from > 0 {
+		prefix = "..."
+	}
+	snippet := prefix + string(runes[from:to])
+	if to < len(runes) {
+		snippet += "..."
+	}
+
+	var highlights []*proto.Highlight
+	shift := len([]rune(prefix)) - from
+	for i, word := range words[first:] {
+		if word.end > to {
+			break
+		}
+		if matches[first+i] {
+			start, end := trimPunctuation(word)
+			highlights = append(highlights, &proto.Highlight{
+				Start: int32(start + shift),
+				End:   int32(end + shift),
+			})
+		}
+	}
+
+	return snippet, highlights
+}
+
+// trimPunctuation narrows a word's offsets to leave out surrounding punctuation
+func trimPunctuation(word pageWord) (int, int) {
+	notWord := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	length := utf8.RuneCountInString(word.text)
+	lead := length - utf8.RuneCountInString(strings.TrimLeftFunc(word.text, notWord))
+	trail := length - utf8.RuneCountInString(strings.TrimRightFunc(word.text, notWord))
+	return word.start + lead, max(word.end-trail, word.start+lead)
+}
+
+// chunkPages returns the first and last page of a chunk, defaulting to page 1
+func chunkPages(chunk *proto.Chunk) (int32, int32) {
+	start := chunk.PageNumber
+	if start == 0 {
+		start = 1 // Default to page 1 if not set
+	}
+	end := chunk.EndPageNumber
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
+// pageLabel formats a page range for display
+func pageLabel(start, end int32) string {
+	if start == end {
+		return fmt.Sprintf("p. %d", start)
+	}
+	return fmt.Sprintf("pp. %d–%d", start, end)
+}
@@ -0,0 +1,133 @@
+// Package tokenizer estimates how many tokens text takes up in a language model
+// prompt, and cuts text to a token budget without splitting UTF-8 sequences.
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Count estimates the number of tokens in text. It follows how BPE tokenizers
+// split text: a run of letters costs about one token per five characters, a
+// run of digits one per three, each punctuation mark or symbol one, and each
+// character of scripts written without spaces (Chinese, Japanese, Korean,
+// Thai) one. The estimate errs on the high side so budgets hold for real
+// tokenizers.
+func Count(text string) int {
+	total := 0
+	scan(text, func(_ int, cost int) bool {
+		total += cost
+		return true
+	})
+	return total
+}
+
+// Truncate returns the longest prefix of text that fits in maxTokens, cut
+// between words where possible and never inside a UTF-8 sequence. It reports
+// whether text was cut.
+func Truncate(text string, maxTokens int) (string, bool) {
+	if maxTokens <= 0 {
+		return "", text != ""
+	}
+
+	total, end := 0, 0
+	scan(text, func(pieceEnd int, cost int) bool {
+		if total+cost > maxTokens {
+			return false
+		}
+		total += cost
+		end = pieceEnd
+		return true
+	})
+
+	if end == len(text) {
+		return text, false
+	}
+	return text[:end], true
+}
+
+// Prefix returns at most maxBytes bytes of text without splitting a UTF-8
+// sequence. It reports whether text was cut.
+func Prefix(text string, maxBytes int) (string, bool) {
+	if len(text) <= maxBytes {
+		return text, false
+	}
+	end := max(maxBytes, 0)
+	for end > 0 && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[:end], true
+}
+
+// scan splits text into the pieces Count prices, calling fn with the byte
+// offset where each piece ends and its token cost until fn returns false
+func scan(text string, fn func(end int, cost int) bool) {
+	i := 0
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+
+		switch {
+		case unicode.IsSpace(r):
+			// Spaces are folded into the next word; a line break is a token of its own
+			cost := 0
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsSpace(next) {
+					break
+				}
+				if next == '\n' {
+					cost = 1
+				}
+				i += nextSize
+			}
+			if r == '\n' {
+				cost = 1
+			}
+			if !fn(i, cost) {
+				return
+			}
+		case unspaced(r):
+			if !fn(i, 1) {
+				return
+			}
+		case unicode.IsDigit(r):
+			runes := 1
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if !unicode.IsDigit(next) {
+					break
+				}
+				runes++
+				i += nextSize
+			}
+			if !fn(i, (runes+2)/3) {
+				return
+			}
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			runes := 1
+			for i < len(text) {
+				next, nextSize := utf8.DecodeRuneInString(text[i:])
+				if !(unicode.IsLetter(next) || unicode.IsMark(next)) || unspaced(next) {
+					break
+				}
+				runes++
+				i += nextSize
+			}
+			if !fn(i, (runes+4)/5) {
+				return
+			}
+		default:
+			// Punctuation, symbols and invalid bytes
+			if !fn(i, 1) {
+				return
+			}
+		}
+	}
+}
+
+// unspaced reports whether r belongs to a script written without spaces
+// between words, which tokenizers split into roughly a token per character
+func unspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Thai)
+}
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"ai-pdf-assistant-backend/database"
@@ -18,68 +27,101 @@ import (
 )
 
 func main() {
+	// Cancel in-flight requests, upstream AI calls and ingestion workers on shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Load environment variables
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, using defaults")
 	}
 
+	// `askmypdf migrate [up | down [steps] | status]` manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize database connection
 	if err := database.Connect(); err != nil {
 		log.Printf("Warning: Could not connect to database: %v", err)
 		log.Println("Continuing with in-memory storage...")
 	} else {
 		defer database.Close()
+
+		// Replicas starting together take turns; only pending migrations run.
+		// Set MIGRATE_ON_START=false to run `migrate up` as a separate deploy step.
+		if os.Getenv("MIGRATE_ON_START") != "false" {
+			if err := database.Migrate(ctx); err != nil {
+				log.Fatalf("Failed to migrate database: %v", err)
+			}
+		}
 	}
 
 	// Initialize repositories
-	docRepo := repositories.NewDocumentRepository()
-	sessionRepo := repositories.NewSessionRepository()
+	stores := newStores()
+	sessionCache := repositories.NewSessionCache()
+	jobRepo := repositories.NewJobRepository()
 
 	// Initialize services
 	uploadDir := os.Getenv("UPLOAD_DIR")
 	if uploadDir == "" {
 		uploadDir = "./uploads"
 	}
-	pdfService := services.NewPDFService(uploadDir)
-	vectorSearch := services.NewVectorSearch()
-
-	// Initialize AI service (Groq, Puter AI, or Mock)
-	var aiService services.AIService
-	groqAPIKey := os.Getenv("GROQ_API_KEY")
-	puterAIURL := os.Getenv("PUTER_AI_URL")
-	puterAIKey := os.Getenv("PUTER_AI_KEY")
 
-	// Priority: Groq > Puter AI > Mock
-	if groqAPIKey != "" {
-		aiService = services.NewGroqAIServiceAdapter(appservices.NewGroqService(groqAPIKey))
-		log.Println("Using Groq AI service")
-	} else if puterAIURL != "" || puterAIKey != "" {
-		aiService = services.NewPuterAIService()
-		log.Println("Using Puter AI service")
-	} else {
-		aiService = services.NewMockAIService()
-		log.Println("Using Mock AI service (set GROQ_API_KEY for real AI)")
+	// Initialize embeddings for semantic search (optional)
+	var denseSearch *services.DenseSearch
+	switch os.Getenv("EMBEDDING_PROVIDER") {
+	case "hash":
+		dimensions, _ := strconv.Atoi(os.Getenv("EMBEDDING_DIMENSIONS"))
+		denseSearch = services.NewDenseSearch(services.NewHashingEmbedder(dimensions))
+		log.Println("Using local hashing embeddings")
+	case "openai":
+		embeddingURL := os.Getenv("EMBEDDING_URL")
+		if embeddingURL == "" {
+			embeddingURL = "https://api.openai.com/v1"
+		}
+		embeddingModel := os.Getenv("EMBEDDING_MODEL")
+		if embeddingModel == "" {
+			embeddingModel = "text-embedding-3-small"
+		}
+		denseSearch = services.NewDenseSearch(services.NewOpenAIEmbedder(embeddingURL, os.Getenv("EMBEDDING_API_KEY"), embeddingModel))
+		log.Printf("Using OpenAI-compatible embeddings (%s)", embeddingModel)
+	case "ollama":
+		ollamaConfig := loadOllamaConfig()
+		denseSearch = services.NewDenseSearch(services.NewOllamaService(ollamaConfig))
+		log.Printf("Using Ollama embeddings (%s)", ollamaConfig.EmbeddingModel)
 	}
 
+	pdfService := services.NewPDFService(uploadDir, denseSearch)
+	vectorSearch := services.NewVectorSearch(os.Getenv("SEARCH_STEMMING") != "false")
+	hybridSearch := services.NewHybridSearch(vectorSearch, denseSearch)
+	retrievalConfig := loadRetrievalConfig(hybridSearch)
+
+	// Initialize AI providers (OpenAI-compatible, Ollama, Groq, Puter AI, or Mock)
+	aiService := newAIService()
+
 	// Initialize use cases
-	pdfUseCase := usecases.NewPDFUseCase(docRepo, sessionRepo, pdfService)
-	chatUseCase := usecases.NewChatUseCase(sessionRepo, aiService, vectorSearch)
-	summaryUseCase := usecases.NewSummaryUseCase(sessionRepo, aiService)
+	sessionManager := usecases.NewSessionManager(sessionCache, stores.sessions, stores.documents, stores.messages, stores.summaries, jobRepo, pdfService, vectorSearch)
+	pdfUseCase := usecases.NewPDFUseCase(stores.documents, sessionManager, jobRepo, pdfService, vectorSearch, envInt("INGESTION_QUEUE_SIZE", 32))
+	pdfUseCase.StartWorkers(ctx, envInt("INGESTION_WORKERS", 2))
+	chatUseCase := usecases.NewChatUseCase(sessionManager, aiService, vectorSearch, hybridSearch, retrievalConfig, loadContextBudget(aiService.ModelInfo()), loadHistoryPolicy(), envBool("GROUNDING_CHECK", false))
+	summaryUseCase := usecases.NewSummaryUseCase(sessionManager, aiService, stores.summaries, envInt("SUMMARY_WORKERS", 4), envInt("SUMMARY_GROUP_TOKENS", 1500))
 
 	// Initialize auth and persistence
-	userRepo := repositories.NewUserRepository()
-	authHandler := handlers.NewAuthHandler(userRepo)
-	persistenceRepo := repositories.NewPersistenceRepository()
-	userHandler := handlers.NewUserHandler(persistenceRepo)
+	authHandler := handlers.NewAuthHandler(stores.users)
+	userHandler := handlers.NewUserHandler(stores.sessions, stores.documents, stores.messages, sessionManager)
 
 	// Initialize handlers
-	pdfHandler := handlers.NewPDFHandler(pdfUseCase, persistenceRepo)
-	chatHandler := handlers.NewChatHandler(chatUseCase, persistenceRepo)
+	pdfHandler := handlers.NewPDFHandler(pdfUseCase)
+	chatHandler := handlers.NewChatHandler(chatUseCase)
 	summaryHandler := handlers.NewSummaryHandler(summaryUseCase)
 
 	// Start session cleanup goroutine
-	go startSessionCleanup(sessionRepo)
+	go startSessionCleanup(sessionManager)
 
 	// Initialize Gin router
 	r := gin.Default()
@@ -154,18 +196,328 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("AskMyPDF API server starting on port %s", port)
-	log.Printf("AI Service: %T", aiService)
-	log.Fatal(r.Run(":" + port))
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		log.Printf("AskMyPDF API server starting on port %s", port)
+		log.Printf("AI Service: %T", aiService)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server error: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down server...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+}
+
+// runMigrate implements the migrate subcommand
+func runMigrate(ctx context.Context, args []string) error {
+	if err := database.Connect(); err != nil {
+		return err
+	}
+	if !database.IsConnected() {
+		return fmt.Errorf("DATABASE_URL is not set")
+	}
+	defer database.Close()
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		return database.Migrate(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("steps must be a positive number")
+			}
+			steps = n
+		}
+		return database.MigrateDown(ctx, steps)
+	case "status":
+		statuses, err := database.Migrations(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range statuses {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, applied)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q (use up, down [steps] or status)", command)
+	}
+}
+
+// envInt reads a positive integer from the environment, falling back to def
+func envInt(key string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// envBool reads a boolean from the environment, falling back to def
+func envBool(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
+
+// loadRetrievalConfig reads retrieval tuning from the environment, falling back to
+// defaults, and checks hybridSearch can serve it
+func loadRetrievalConfig(hybridSearch *services.HybridSearch) services.RetrievalConfig {
+	cfg := services.DefaultRetrievalConfig()
+	if v, err := strconv.Atoi(os.Getenv("RETRIEVAL_TOP_K")); err == nil {
+		cfg.TopK = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("RETRIEVAL_KEYWORD_WEIGHT"), 64); err == nil {
+		cfg.KeywordWeight = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("RETRIEVAL_DENSE_WEIGHT"), 64); err == nil {
+		cfg.DenseWeight = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("RETRIEVAL_RRF_K")); err == nil {
+		cfg.RRFK = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("RETRIEVAL_REWRITE_QUERY")); err == nil {
+		cfg.RewriteQuery = v
+	}
+
+	if err := hybridSearch.Validate(cfg); err != nil {
+		log.Fatalf("Invalid retrieval configuration: %v", err)
+	}
+	log.Printf("Retrieval: top_k=%d keyword_weight=%.2f dense_weight=%.2f rrf_k=%d rewrite_query=%t",
+		cfg.TopK, cfg.KeywordWeight, cfg.DenseWeight, cfg.RRFK, cfg.RewriteQuery)
+
+	return cfg
+}
+
+// loadContextBudget reads how a question prompt shares the model's context window
+func loadContextBudget(model services.ModelInfo) services.ContextBudget {
+	cfg := services.DefaultContextBudget(model.ContextWindow)
+	if v, err := strconv.Atoi(os.Getenv("CONTEXT_MAX_PROMPT_TOKENS")); err == nil && v >= 0 {
+		cfg.MaxPromptTokens = v
+	}
+	cfg.AnswerReserve = envInt("CONTEXT_ANSWER_RESERVE_TOKENS", cfg.AnswerReserve)
+	if v, err := strconv.ParseFloat(os.Getenv("CONTEXT_HISTORY_SHARE"), 64); err == nil {
+		cfg.HistoryShare = v
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid context budget: %v", err)
+	}
+	log.Printf("Context budget: window=%d max_prompt=%d answer_reserve=%d history_share=%.2f",
+		cfg.ContextWindow, cfg.MaxPromptTokens, cfg.AnswerReserve, cfg.HistoryShare)
+
+	return cfg
+}
+
+// loadHistoryPolicy reads which earlier messages are sent with a question
+func loadHistoryPolicy() usecases.HistoryPolicy {
+	policy := usecases.HistoryPolicy{
+		Mode:     os.Getenv("HISTORY_POLICY"),
+		MaxTurns: envInt("HISTORY_MAX_TURNS", 5),
+	}
+	if policy.Mode == "" {
+		policy.Mode = usecases.HistoryPolicyBudget
+	}
+
+	if err := policy.Validate(); err != nil {
+		log.Fatalf("Invalid history policy: %v", err)
+	}
+	log.Printf("History policy: %s", policy.Mode)
+
+	return policy
+}
+
+// newAIService creates the chain of AI providers named by AI_PROVIDERS (e.g.
+// "groq,ollama,mock"), or the single one named by AI_PROVIDER. Without either,
+// every configured provider is chained: an OpenAI-compatible server, Ollama,
+// Groq, then Puter AI, with Mock only when none is configured. Transient
+// failures are retried and a failing provider hands over to the next one.
+func newAIService() services.AIService {
+	var names []string
+	switch {
+	case os.Getenv("AI_PROVIDERS") != "":
+		for _, name := range strings.Split(os.Getenv("AI_PROVIDERS"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	case os.Getenv("AI_PROVIDER") != "":
+		names = []string{os.Getenv("AI_PROVIDER")}
+	default:
+		if os.Getenv("LLM_BASE_URL") != "" {
+			names = append(names, "openai")
+		}
+		if os.Getenv("OLLAMA_URL") != "" {
+			names = append(names, "ollama")
+		}
+		if os.Getenv("GROQ_API_KEY") != "" {
+			names = append(names, "groq")
+		}
+		if os.Getenv("PUTER_AI_URL") != "" || os.Getenv("PUTER_AI_KEY") != "" {
+			names = append(names, "puter")
+		}
+	}
+	if len(names) == 0 {
+		names = []string{"mock"}
+	}
+
+	providers := make([]services.AIService, len(names))
+	for i, name := range names {
+		providers[i] = newAIProvider(name)
+	}
+	if len(providers) == 1 && names[0] == "mock" {
+		return providers[0]
+	}
+
+	cfg := services.DefaultFallbackConfig()
+	if v, err := strconv.Atoi(os.Getenv("AI_MAX_RETRIES")); err == nil && v >= 0 {
+		cfg.MaxRetries = v
+	}
+	cfg.BaseDelay = time.Duration(envInt("AI_RETRY_BASE_MS", int(cfg.BaseDelay/time.Millisecond))) * time.Millisecond
+	cfg.MaxDelay = time.Duration(envInt("AI_RETRY_MAX_MS", int(cfg.MaxDelay/time.Millisecond))) * time.Millisecond
+	cfg.FailureThreshold = envInt("AI_BREAKER_THRESHOLD", cfg.FailureThreshold)
+	cfg.Cooldown = time.Duration(envInt("AI_BREAKER_COOLDOWN_SECONDS", int(cfg.Cooldown/time.Second))) * time.Second
+	log.Printf("AI provider chain: %s (retries=%d, breaker after %d failures for %s)",
+		strings.Join(names, " -> "), cfg.MaxRetries, cfg.FailureThreshold, cfg.Cooldown)
+
+	return services.NewFallbackAIService(providers, cfg)
+}
+
+// newAIProvider creates one AI provider by name
+func newAIProvider(name string) services.AIService {
+	switch name {
+	case "openai":
+		aiService, err := services.NewOpenAICompatibleService(loadOpenAICompatibleConfig())
+		if err != nil {
+			log.Fatalf("Invalid OpenAI-compatible provider configuration: %v", err)
+		}
+		info := aiService.ModelInfo()
+		log.Printf("Using OpenAI-compatible AI service %s (%s)", info.Provider, info.Model)
+		return aiService
+	case "ollama":
+		aiService := services.NewOllamaService(loadOllamaConfig())
+		log.Printf("Using Ollama AI service (%s)", aiService.ModelInfo().Model)
+		return aiService
+	case "groq":
+		log.Println("Using Groq AI service")
+		return services.NewGroqAIServiceAdapter(appservices.NewGroqService(os.Getenv("GROQ_API_KEY")))
+	case "puter":
+		log.Println("Using Puter AI service")
+		return services.NewPuterAIService()
+	case "mock":
+		log.Println("Using Mock AI service (set GROQ_API_KEY for real AI)")
+		return services.NewMockAIService()
+	default:
+		log.Fatalf("Unknown AI provider %q (use openai, ollama, groq, puter or mock)", name)
+		return nil
+	}
+}
+
+// loadOpenAICompatibleConfig reads the OpenAI-compatible provider settings from the environment
+func loadOpenAICompatibleConfig() services.OpenAICompatibleConfig {
+	cfg := services.OpenAICompatibleConfig{
+		Name:          os.Getenv("LLM_PROVIDER_NAME"),
+		BaseURL:       os.Getenv("LLM_BASE_URL"),
+		Model:         os.Getenv("LLM_MODEL"),
+		APIKey:        os.Getenv("LLM_API_KEY"),
+		APIKeyHeader:  os.Getenv("LLM_API_KEY_HEADER"),
+		Temperature:   0.3,
+		MaxTokens:     envInt("LLM_MAX_TOKENS", 0),
+		ContextWindow: envInt("LLM_CONTEXT_WINDOW", 8192),
+		JSONMode:      os.Getenv("LLM_JSON_MODE") != "false",
+		Timeout:       time.Duration(envInt("LLM_TIMEOUT_SECONDS", 60)) * time.Second,
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("LLM_TEMPERATURE"), 64); err == nil {
+		cfg.Temperature = v
+	}
+	if headers := os.Getenv("LLM_HEADERS"); headers != "" {
+		if err := json.Unmarshal([]byte(headers), &cfg.Headers); err != nil {
+			log.Fatalf("LLM_HEADERS must be a JSON object of header names to values: %v", err)
+		}
+	}
+
+	return cfg
+}
+
+// loadOllamaConfig reads the Ollama settings from the environment
+func loadOllamaConfig() services.OllamaConfig {
+	cfg := services.OllamaConfig{
+		BaseURL:        os.Getenv("OLLAMA_URL"),
+		Model:          os.Getenv("OLLAMA_MODEL"),
+		EmbeddingModel: os.Getenv("OLLAMA_EMBEDDING_MODEL"),
+		KeepAlive:      os.Getenv("OLLAMA_KEEP_ALIVE"),
+		Temperature:    0.3,
+		NumCtx:         envInt("OLLAMA_NUM_CTX", 0),
+		Timeout:        time.Duration(envInt("OLLAMA_TIMEOUT_SECONDS", 300)) * time.Second,
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("OLLAMA_TEMPERATURE"), 64); err == nil {
+		cfg.Temperature = v
+	}
+
+	return cfg
+}
+
+// storeSet holds the stores the application persists to
+type storeSet struct {
+	sessions  repositories.SessionStore
+	documents repositories.DocumentStore
+	messages  repositories.MessageStore
+	users     repositories.UserStore
+	summaries repositories.SummaryStore
+}
+
+// newStores uses the database for everything when connected, otherwise memory for everything
+func newStores() storeSet {
+	if database.IsConnected() {
+		log.Printf("Using %s storage", database.CurrentDialect)
+		return storeSet{
+			sessions:  repositories.NewSQLSessionStore(database.DB),
+			documents: repositories.NewSQLDocumentStore(database.DB, database.CurrentDialect),
+			messages:  repositories.NewSQLMessageStore(database.DB),
+			users:     repositories.NewSQLUserStore(database.DB),
+			summaries: repositories.NewSQLSummaryStore(database.DB),
+		}
+	}
+
+	log.Println("Using in-memory storage; data is lost on restart")
+	return storeSet{
+		sessions:  repositories.NewMemorySessionStore(),
+		documents: repositories.NewMemoryDocumentStore(),
+		messages:  repositories.NewMemoryMessageStore(),
+		users:     repositories.NewMemoryUserStore(),
+		summaries: repositories.NewMemorySummaryStore(),
+	}
 }
 
 // startSessionCleanup periodically cleans up inactive sessions
-func startSessionCleanup(sessionRepo *repositories.SessionRepository) {
+func startSessionCleanup(sessionManager *usecases.SessionManager) {
 	ticker := time.NewTicker(1 * time.Hour) // Run every hour
 	defer ticker.Stop()
 
 	for range ticker.C {
-		cleaned := sessionRepo.CleanupInactive(1 * time.Hour) // Remove sessions inactive for 1 hour
+		cleaned := sessionManager.CleanupInactive(1 * time.Hour) // Evict sessions inactive for 1 hour
 		if cleaned > 0 {
 			log.Printf("Cleaned up %d inactive sessions", cleaned)
 		}
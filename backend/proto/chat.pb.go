@@ -22,19 +22,70 @@ type ChatSession struct {
 
 // ChatRequest represents a chat message request
 type ChatRequest struct {
-	SessionId string `json:"session_id"`
-	Message   string `json:"message"`
+	SessionId     string   `json:"session_id"`
+	Message       string   `json:"message"`
+	TopK          int32    `json:"top_k,omitempty"`          // 0 uses the deployment default
+	KeywordWeight *float64 `json:"keyword_weight,omitempty"` // nil uses the deployment default
+	DenseWeight   *float64 `json:"dense_weight,omitempty"`   // nil uses the deployment default
+	RewriteQuery  *bool    `json:"rewrite_query,omitempty"`  // nil uses the deployment default
 }
 
 // ChatResponse represents a chat message response
 type ChatResponse struct {
-	Status         Status      `json:"status"`
-	Response       string      `json:"response,omitempty"`
-	SessionId      string      `json:"session_id,omitempty"`
-	RelevantChunks []string    `json:"relevant_chunks,omitempty"`
-	AnswerFound    bool        `json:"answer_found"`
-	Citations      interface{} `json:"citations,omitempty"`
-	Error          *Error      `json:"error,omitempty"`
+	Status          Status            `json:"status"`
+	Response        string            `json:"response,omitempty"`
+	SessionId       string            `json:"session_id,omitempty"`
+	RelevantChunks  []string          `json:"relevant_chunks,omitempty"`
+	RetrievedChunks []*RetrievedChunk `json:"retrieved_chunks,omitempty"`
+	AnswerFound     bool              `json:"answer_found"`
+	Confidence      float64           `json:"confidence"`          // 0 to 1: how sure the answer_found verdict is
+	Citations       []*Citation       `json:"citations,omitempty"` // Chunks the answer cites
+	Sentences       []*AnswerSentence `json:"sentences,omitempty"` // The answer's sentences and the chunks each cites
+	Provider        string            `json:"provider,omitempty"`  // AI provider that answered
+	Model           string            `json:"model,omitempty"`
+	RewrittenQuery  string            `json:"rewritten_query,omitempty"` // Search query used in place of the message, if rewritten
+	Error           *Error            `json:"error,omitempty"`
+}
+
+// Citation points at the passage of a document an answer drew on
+type Citation struct {
+	DocumentId  string       `json:"document_id"`
+	Filename    string       `json:"filename"`
+	ChunkId     string       `json:"chunk_id"`
+	Page        int32        `json:"page"`
+	EndPage     int32        `json:"end_page"`
+	Label       string       `json:"label"`        // e.g. "p. 4" or "pp. 12–13"
+	StartOffset int32        `json:"start_offset"` // Character offset of the passage in the text of Page
+	EndOffset   int32        `json:"end_offset"`   // Character offset just past the passage in the text of EndPage
+	Snippet     string       `json:"snippet"`      // Excerpt of the passage around the words of the question
+	Highlights  []*Highlight `json:"highlights,omitempty"`
+	Marker      int32        `json:"marker"`              // Number of the chunk in the prompt, as cited by [n] markers
+	Sentences   []int32      `json:"sentences,omitempty"` // Indices of the answer sentences it supports
+}
+
+// AnswerSentence is a sentence of an answer with the chunks it cites
+type AnswerSentence struct {
+	Text      string  `json:"text"`
+	Start     int32   `json:"start"` // Character offset in the answer
+	End       int32   `json:"end"`
+	Citations []int32 `json:"citations,omitempty"` // Markers of the chunks it cites
+	Supported bool    `json:"supported"`           // Whether it cites at least one chunk
+}
+
+// Highlight marks a range of a citation snippet that matches the question
+type Highlight struct {
+	Start int32 `json:"start"` // Character offset in the snippet
+	End   int32 `json:"end"`
+}
+
+// RetrievedChunk describes a chunk used as context and how it was found
+type RetrievedChunk struct {
+	ChunkId       string  `json:"chunk_id"`
+	PageNumber    int32   `json:"page_number"`
+	EndPageNumber int32   `json:"end_page_number"`
+	Score         float64 `json:"score"`
+	Retriever     string  `json:"retriever"` // "keyword", "dense", "hybrid" or "fallback"
+	Text          string  `json:"text"`
 }
 
 // HistoryRequest represents a chat history request
@@ -14,11 +14,14 @@ type Document struct {
 
 // Chunk represents a text chunk
 type Chunk struct {
-	Id         string   `json:"id"`
-	Text       string   `json:"text"`
-	ChunkIndex int32    `json:"chunk_index"`
-	PageNumber int32    `json:"page_number"`
-	Embedding  []float32 `json:"embedding,omitempty"`
+	Id            string    `json:"id"`
+	Text          string    `json:"text"`
+	ChunkIndex    int32     `json:"chunk_index"`
+	PageNumber    int32     `json:"page_number"`     // First page the chunk appears on
+	EndPageNumber int32     `json:"end_page_number"` // Last page the chunk appears on
+	StartOffset   int32     `json:"start_offset"`    // Character offset of the chunk in the text of its first page
+	EndOffset     int32     `json:"end_offset"`      // Character offset just past the chunk in the text of its last page
+	Embedding     []float32 `json:"embedding,omitempty"`
 }
 
 // UploadRequest represents a PDF upload request
@@ -42,23 +45,73 @@ type StatusRequest struct {
 
 // StatusResponse represents a document status response
 type StatusResponse struct {
-	Status   Status    `json:"status"`
-	Document *Document `json:"document,omitempty"`
-	Error    *Error    `json:"error,omitempty"`
+	Status    Status           `json:"status"`
+	Document  *Document        `json:"document,omitempty"`
+	Ingestion *IngestionStatus `json:"ingestion,omitempty"`
+	Error     *Error           `json:"error,omitempty"`
+}
+
+// IngestionStatus represents the progress of background PDF processing
+type IngestionStatus struct {
+	State      string `json:"state"` // "queued", "processing", "ready" or "failed"
+	PagesDone  int32  `json:"pages_done"`
+	PagesTotal int32  `json:"pages_total"`
+	Error      string `json:"error,omitempty"`
 }
 
 // SummaryRequest represents a summary request
 type SummaryRequest struct {
-	SessionId  string `json:"session_id"`
-	DocumentId string `json:"document_id,omitempty"`
+	SessionId   string   `json:"session_id"`
+	DocumentId  string   `json:"document_id,omitempty"`  // Summarize one document
+	DocumentIds []string `json:"document_ids,omitempty"` // Summarize a subset; all documents when both are empty
+	Mode        string   `json:"mode,omitempty"`         // "per_document" (default) or "combined"
+	Refresh     bool     `json:"refresh,omitempty"`      // Regenerate instead of serving a cached summary
 }
 
 // SummaryResponse represents a summary response
 type SummaryResponse struct {
-	Status       Status   `json:"status"`
-	Summary      string   `json:"summary,omitempty"`
-	KeyTakeaways []string `json:"key_takeaways,omitempty"`
-	MainTopics   []string `json:"main_topics,omitempty"`
-	Error        *Error   `json:"error,omitempty"`
+	Status          Status             `json:"status"`
+	Summary         string             `json:"summary,omitempty"`
+	KeyTakeaways    []string           `json:"key_takeaways,omitempty"`
+	MainTopics      []string           `json:"main_topics,omitempty"`
+	Mode            string             `json:"mode,omitempty"`
+	Documents       []*DocumentSummary `json:"documents,omitempty"` // One entry per summarized document
+	Takeaways       []*Takeaway        `json:"takeaways,omitempty"` // Key takeaways with their source document
+	Entities        []*Entity          `json:"entities,omitempty"`
+	PageReferences  []*PageReference   `json:"page_references,omitempty"`
+	CoveragePercent float64            `json:"coverage_percent"` // Share of the document text that was summarized
+	Cached          bool               `json:"cached"`           // Served from the summary cache
+	Error           *Error             `json:"error,omitempty"`
+}
+
+// DocumentSummary represents the summary of a single document
+type DocumentSummary struct {
+	DocumentId      string           `json:"document_id"`
+	Filename        string           `json:"filename"`
+	Summary         string           `json:"summary"`
+	KeyTakeaways    []string         `json:"key_takeaways"`
+	MainTopics      []string         `json:"main_topics"`
+	Entities        []*Entity        `json:"entities"`
+	PageReferences  []*PageReference `json:"page_references"`
+	CoveragePercent float64          `json:"coverage_percent"`
+}
+
+// Takeaway represents a key takeaway attributed to the document it came from
+type Takeaway struct {
+	Text       string `json:"text"`
+	DocumentId string `json:"document_id"`
+	Filename   string `json:"filename"`
+}
+
+// Entity represents a named person, organization, place or concept in a document
+type Entity struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"` // e.g. "person", "organization", "location"
 }
 
+// PageReference points to a page that supports a point in a summary
+type PageReference struct {
+	DocumentId string `json:"document_id,omitempty"`
+	Page       int32  `json:"page"`
+	Point      string `json:"point"`
+}
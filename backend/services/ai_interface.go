@@ -1,8 +1,10 @@
 package services
 
+import "context"
+
 // AIProvider interface that both OpenAI and Groq services implement
 type AIProvider interface {
-	ChatWithPDF(pdfText, userQuestion, sessionID string) (*ChatResponse, error)
-	ChatWithContext(pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error)
-	SummarizePDF(pdfText string) (string, error)
-}
\ No newline at end of file
+	ChatWithPDF(ctx context.Context, pdfText, userQuestion, sessionID string) (*ChatResponse, error)
+	ChatWithContext(ctx context.Context, pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error)
+	SummarizePDF(ctx context.Context, pdfText string) (string, error)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"strings"
 
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -31,7 +33,7 @@ func NewAIService(apiKey string) *AIService {
 	}
 }
 
-func (ai *AIService) ChatWithPDF(pdfText, userQuestion, sessionID string) (*ChatResponse, error) {
+func (ai *AIService) ChatWithPDF(ctx context.Context, pdfText, userQuestion, sessionID string) (*ChatResponse, error) {
 	// Create context-aware prompt
 	systemPrompt := fmt.Sprintf(`You are an AI assistant helping users understand and analyze PDF documents. 
 
@@ -53,7 +55,7 @@ Please answer questions about this document accurately and helpfully. If the ans
 	}
 
 	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model:       ai.model,
 			Messages:    messages,
@@ -76,7 +78,7 @@ Please answer questions about this document accurately and helpfully. If the ans
 	}, nil
 }
 
-func (ai *AIService) ChatWithContext(pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error) {
+func (ai *AIService) ChatWithContext(ctx context.Context, pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error) {
 	// Build conversation with PDF context
 	messages := []openai.ChatCompletionMessage{
 		{
@@ -97,7 +99,7 @@ Please answer questions about this document accurately and helpfully. Maintain c
 		if msg.Role == "assistant" {
 			role = openai.ChatMessageRoleAssistant
 		}
-		
+
 		messages = append(messages, openai.ChatCompletionMessage{
 			Role:    role,
 			Content: msg.Content,
@@ -111,7 +113,7 @@ Please answer questions about this document accurately and helpfully. Maintain c
 	})
 
 	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model:       ai.model,
 			Messages:    messages,
@@ -134,26 +136,26 @@ Please answer questions about this document accurately and helpfully. Maintain c
 	}, nil
 }
 
-func (ai *AIService) SummarizePDF(pdfText string) (string, error) {
+func (ai *AIService) SummarizePDF(ctx context.Context, pdfText string) (string, error) {
 	// Truncate text if it's too long for the API
-	maxLength := 12000 // Leave room for prompt and response
-	if len(pdfText) > maxLength {
-		pdfText = pdfText[:maxLength] + "... [content truncated]"
+	maxTokens := 3000 // Leave room for prompt and response
+	if truncated, cut := tokenizer.Truncate(pdfText, maxTokens); cut {
+		pdfText = truncated + "... [content truncated]"
 	}
 
 	messages := []openai.ChatCompletionMessage{
 		{
-			Role: openai.ChatMessageRoleSystem,
+			Role:    openai.ChatMessageRoleSystem,
 			Content: "You are an AI assistant that creates concise, informative summaries of PDF documents. Focus on the main points, key findings, and important details.",
 		},
 		{
-			Role: openai.ChatMessageRoleUser,
+			Role:    openai.ChatMessageRoleUser,
 			Content: fmt.Sprintf("Please provide a comprehensive summary of the following PDF content:\n\n%s", pdfText),
 		},
 	}
 
 	resp, err := ai.client.CreateChatCompletion(
-		context.Background(),
+		ctx,
 		openai.ChatCompletionRequest{
 			Model:       ai.model,
 			Messages:    messages,
@@ -171,4 +173,4 @@ func (ai *AIService) SummarizePDF(pdfText string) (string, error) {
 	}
 
 	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
-}
\ No newline at end of file
+}
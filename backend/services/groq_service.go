@@ -9,13 +9,17 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"ai-pdf-assistant-backend/infrastructure/chatapi"
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
 )
 
 type GroqService struct {
-	apiKey  string
-	baseURL string
-	model   string
-	client  *http.Client
+	apiKey        string
+	baseURL       string
+	model         string
+	contextWindow int // Tokens the model accepts, prompt and answer together
+	client        *http.Client
 }
 
 type GroqMessage struct {
@@ -24,11 +28,17 @@ type GroqMessage struct {
 }
 
 type GroqRequest struct {
-	Messages    []GroqMessage `json:"messages"`
-	Model       string        `json:"model"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	Stream      bool          `json:"stream,omitempty"`
+	Messages       []GroqMessage       `json:"messages"`
+	Model          string              `json:"model"`
+	MaxTokens      int                 `json:"max_tokens,omitempty"`
+	Temperature    float64             `json:"temperature,omitempty"`
+	Stream         bool                `json:"stream,omitempty"`
+	ResponseFormat *GroqResponseFormat `json:"response_format,omitempty"`
+}
+
+// GroqResponseFormat constrains the reply, e.g. {"type": "json_object"}
+type GroqResponseFormat struct {
+	Type string `json:"type"`
 }
 
 type GroqChoice struct {
@@ -54,16 +64,27 @@ type GroqResponse struct {
 
 func NewGroqService(apiKey string) *GroqService {
 	return &GroqService{
-		apiKey:  apiKey,
-		baseURL: "https://api.groq.com/openai/v1",
-		model:   "llama-3.3-70b-versatile", // 128K context window for full PDF support
+		apiKey:        apiKey,
+		baseURL:       "https://api.groq.com/openai/v1",
+		model:         "llama-3.3-70b-versatile", // 128K context window for full PDF support
+		contextWindow: 131072,
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
 	}
 }
 
-func (g *GroqService) ChatWithPDF(pdfText, userQuestion, sessionID string) (*ChatResponse, error) {
+// Model returns the name of the model requests are sent to
+func (g *GroqService) Model() string {
+	return g.model
+}
+
+// ContextWindow returns how many tokens the model accepts, prompt and answer together
+func (g *GroqService) ContextWindow() int {
+	return g.contextWindow
+}
+
+func (g *GroqService) ChatWithPDF(ctx context.Context, pdfText, userQuestion, sessionID string) (*ChatResponse, error) {
 	// Create context-aware prompt
 	systemPrompt := fmt.Sprintf(`You are an AI assistant helping users understand and analyze PDF documents. 
 
@@ -84,7 +105,25 @@ Please answer questions about this document accurately and helpfully. If the ans
 		},
 	}
 
-	resp, err := g.makeRequest(messages, 1000, 0.7)
+	resp, err := g.makeRequest(ctx, messages, 1000, 0.7)
+	if err != nil {
+		return nil, fmt.Errorf("Groq API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from Groq")
+	}
+
+	return &ChatResponse{
+		Message:   resp.Choices[0].Message.Content,
+		SessionID: sessionID,
+	}, nil
+}
+
+func (g *GroqService) ChatWithContext(ctx context.Context, pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error) {
+	messages := buildContextMessages(pdfText, userQuestion, conversationHistory, g.maxContextTokens())
+
+	resp, err := g.makeRequest(ctx, messages, 1000, 0.7)
 	if err != nil {
 		return nil, fmt.Errorf("Groq API error: %w", err)
 	}
@@ -99,11 +138,37 @@ Please answer questions about this document accurately and helpfully. If the ans
 	}, nil
 }
 
-func (g *GroqService) ChatWithContext(pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string) (*ChatResponse, error) {
-	// Truncate context if it's extremely long (safety net for very large PDFs)
-	maxContextLen := 100000 // ~100K chars, well within 128K token limit
-	if len(pdfText) > maxContextLen {
-		pdfText = pdfText[:maxContextLen] + "\n... [content truncated due to length]"
+// StreamChatWithContext is like ChatWithContext but calls onDelta with each piece of
+// the answer as Groq generates it. The complete answer is returned at the end.
+func (g *GroqService) StreamChatWithContext(ctx context.Context, pdfText, userQuestion string, conversationHistory []ChatMessage, sessionID string, onDelta func(string) error) (*ChatResponse, error) {
+	messages := buildContextMessages(pdfText, userQuestion, conversationHistory, g.maxContextTokens())
+
+	answer, err := g.makeStreamRequest(ctx, messages, 1000, 0.7, onDelta)
+	if err != nil {
+		return nil, fmt.Errorf("Groq API error: %w", err)
+	}
+
+	if answer == "" {
+		return nil, fmt.Errorf("no response from Groq")
+	}
+
+	return &ChatResponse{
+		Message:   answer,
+		SessionID: sessionID,
+	}, nil
+}
+
+// maxContextTokens is the most document context sent with a question, leaving
+// room for the instructions, conversation history and answer
+func (g *GroqService) maxContextTokens() int {
+	return g.contextWindow - 4096
+}
+
+// buildContextMessages builds the conversation sent for context-aware chat
+func buildContextMessages(pdfText, userQuestion string, conversationHistory []ChatMessage, maxContextTokens int) []GroqMessage {
+	// Truncate context if it's extremely long (safety net; callers budget the context already)
+	if truncated, cut := tokenizer.Truncate(pdfText, maxContextTokens); cut {
+		pdfText = truncated + "\n... [content truncated due to length]"
 	}
 
 	// Build conversation with PDF context
@@ -116,15 +181,15 @@ Here is the content of the PDF document:
 
 %s
 
-Please answer questions about this document accurately and helpfully. Maintain context from previous messages in this conversation. If the answer is not found in the document, clearly state that the information is not available in the provided PDF.`, pdfText),
+Please answer questions about this document accurately and helpfully. Maintain context from previous messages in this conversation. If the answer is not found in the document, clearly state that the information is not available in the provided PDF. After each sentence, cite the numbered chunks of the content that support it with markers such as [1] or [2][3].`, pdfText),
 		},
 	}
 
 	// Add conversation history
 	for _, msg := range conversationHistory {
 		role := "user"
-		if msg.Role == "assistant" {
-			role = "assistant"
+		if msg.Role == "assistant" || msg.Role == "system" {
+			role = msg.Role
 		}
 
 		messages = append(messages, GroqMessage{
@@ -139,26 +204,14 @@ Please answer questions about this document accurately and helpfully. Maintain c
 		Content: userQuestion,
 	})
 
-	resp, err := g.makeRequest(messages, 1000, 0.7)
-	if err != nil {
-		return nil, fmt.Errorf("Groq API error: %w", err)
-	}
-
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("no response from Groq")
-	}
-
-	return &ChatResponse{
-		Message:   resp.Choices[0].Message.Content,
-		SessionID: sessionID,
-	}, nil
+	return messages
 }
 
-func (g *GroqService) SummarizePDF(pdfText string) (string, error) {
+func (g *GroqService) SummarizePDF(ctx context.Context, pdfText string) (string, error) {
 	// Truncate text if it's too long for the API
-	maxLength := 12000 // Leave room for prompt and response
-	if len(pdfText) > maxLength {
-		pdfText = pdfText[:maxLength] + "... [content truncated]"
+	maxTokens := 3000 // Leave room for prompt and response
+	if truncated, cut := tokenizer.Truncate(pdfText, maxTokens); cut {
+		pdfText = truncated + "... [content truncated]"
 	}
 
 	messages := []GroqMessage{
@@ -172,7 +225,7 @@ func (g *GroqService) SummarizePDF(pdfText string) (string, error) {
 		},
 	}
 
-	resp, err := g.makeRequest(messages, 500, 0.5)
+	resp, err := g.makeRequest(ctx, messages, 500, 0.5)
 	if err != nil {
 		return "", fmt.Errorf("Groq API error: %w", err)
 	}
@@ -184,21 +237,58 @@ func (g *GroqService) SummarizePDF(pdfText string) (string, error) {
 	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
 }
 
-func (g *GroqService) makeRequest(messages []GroqMessage, maxTokens int, temperature float64) (*GroqResponse, error) {
-	reqBody := GroqRequest{
+// Complete sends messages and returns the reply text, with little randomness
+func (g *GroqService) Complete(ctx context.Context, messages []GroqMessage, maxTokens int) (string, error) {
+	resp, err := g.makeRequest(ctx, messages, maxTokens, 0.1)
+	if err != nil {
+		return "", fmt.Errorf("Groq API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from Groq")
+	}
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// CompleteJSON sends messages in JSON mode, so the reply is a single JSON object
+func (g *GroqService) CompleteJSON(ctx context.Context, messages []GroqMessage, maxTokens int) (string, error) {
+	resp, err := g.sendRequest(ctx, GroqRequest{
+		Messages:       messages,
+		Model:          g.model,
+		MaxTokens:      maxTokens,
+		Temperature:    0.3,
+		ResponseFormat: &GroqResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("Groq API error: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from Groq")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (g *GroqService) makeRequest(ctx context.Context, messages []GroqMessage, maxTokens int, temperature float64) (*GroqResponse, error) {
+	return g.sendRequest(ctx, GroqRequest{
 		Messages:    messages,
 		Model:       g.model,
 		MaxTokens:   maxTokens,
 		Temperature: temperature,
 		Stream:      false,
-	}
+	})
+}
 
+// sendRequest sends a non-streamed completion request
+func (g *GroqService) sendRequest(ctx context.Context, reqBody GroqRequest) (*GroqResponse, error) {
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(context.Background(), "POST", g.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -218,7 +308,7 @@ func (g *GroqService) makeRequest(messages []GroqMessage, maxTokens int, tempera
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, chatapi.NewAPIError(resp, body)
 	}
 
 	var groqResp GroqResponse
@@ -229,3 +319,42 @@ func (g *GroqService) makeRequest(messages []GroqMessage, maxTokens int, tempera
 
 	return &groqResp, nil
 }
+
+// makeStreamRequest sends a streamed completion request, forwarding each
+// content delta to onDelta.
+func (g *GroqService) makeStreamRequest(ctx context.Context, messages []GroqMessage, maxTokens int, temperature float64, onDelta func(string) error) (string, error) {
+	reqBody := GroqRequest{
+		Messages:    messages,
+		Model:       g.model,
+		MaxTokens:   maxTokens,
+		Temperature: temperature,
+		Stream:      true,
+	}
+
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", chatapi.NewAPIError(resp, body)
+	}
+
+	return chatapi.ReadStream(resp.Body, onDelta)
+}
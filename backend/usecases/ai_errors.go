@@ -0,0 +1,39 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"ai-pdf-assistant-backend/proto"
+)
+
+// Error codes for AI service failures
+const (
+	ErrCodeRequestCancelled = "REQUEST_CANCELLED"
+	ErrCodeAITimeout        = "AI_SERVICE_TIMEOUT"
+	ErrCodeAIService        = "AI_SERVICE_ERROR"
+)
+
+// aiServiceError describes a failed AI service call. Client disconnects and
+// timeouts are reported with their own codes so they aren't mistaken for
+// provider errors.
+func aiServiceError(ctx context.Context, err error, action string) *proto.Error {
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return &proto.Error{
+			Code:    ErrCodeRequestCancelled,
+			Message: fmt.Sprintf("Request cancelled while trying to %s", action),
+		}
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &proto.Error{
+			Code:    ErrCodeAITimeout,
+			Message: fmt.Sprintf("Timed out while trying to %s", action),
+		}
+	default:
+		return &proto.Error{
+			Code:    ErrCodeAIService,
+			Message: fmt.Sprintf("Failed to %s: %v", action, err),
+		}
+	}
+}
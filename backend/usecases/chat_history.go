@@ -0,0 +1,154 @@
+package usecases
+
+import (
+	"ai-pdf-assistant-backend/infrastructure/services"
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+	"ai-pdf-assistant-backend/proto"
+	"context"
+	"fmt"
+)
+
+// History policies
+const (
+	HistoryPolicyTurns   = "turns"   // The last MaxTurns turns, as far as they fit the token budget
+	HistoryPolicyBudget  = "budget"  // As many recent messages as fit the token budget
+	HistoryPolicySummary = "summary" // Recent messages that fit, after a rolling summary of the older ones
+)
+
+// historySummaryTokens bounds the rolling summary of older messages
+const historySummaryTokens = 400
+
+// historySummaryPrefix introduces the rolling summary in the history sent to providers
+const historySummaryPrefix = "Summary of the earlier conversation: "
+
+// HistoryPolicy decides which earlier messages are sent with a question
+type HistoryPolicy struct {
+	Mode     string
+	MaxTurns int // Turns kept by HistoryPolicyTurns; a turn is a question and its answer
+}
+
+// Validate checks the policy is usable
+func (p HistoryPolicy) Validate() error {
+	switch p.Mode {
+	case HistoryPolicyTurns:
+		if p.MaxTurns <= 0 {
+			return fmt.Errorf("max turns must be positive for the %q history policy", p.Mode)
+		}
+	case HistoryPolicyBudget, HistoryPolicySummary:
+	default:
+		return fmt.Errorf("unknown history policy %q (use %s, %s or %s)", p.Mode, HistoryPolicyTurns, HistoryPolicyBudget, HistoryPolicySummary)
+	}
+	return nil
+}
+
+// historySummary is a rolling summary of a session's older messages
+type historySummary struct {
+	covered int    // Number of older messages summarized
+	lastID  string // ID of the last message summarized, to notice a cleared session
+	text    string
+}
+
+// conversationHistory fits the earlier messages of a session and the ranked
+// chunks into the context budget, following the history policy
+func (uc *ChatUseCase) conversationHistory(ctx context.Context, sessionID string, messages []*proto.ChatMessage, question string, chunks []*proto.Chunk) services.PromptAllocation {
+	var conversation []*proto.ChatMessage
+	for _, msg := range messages {
+		if msg.Role == "user" || msg.Role == "assistant" {
+			conversation = append(conversation, msg)
+		}
+	}
+
+	// The turns policy keeps only the last MaxTurns questions and their answers
+	start := 0
+	if uc.history.Mode == HistoryPolicyTurns {
+		start = len(conversation)
+		turns := 0
+		for start > 0 {
+			if conversation[start-1].Role == "user" {
+				if turns == uc.history.MaxTurns {
+					break
+				}
+				turns++
+			}
+			start--
+		}
+	}
+	kept := conversation[start:]
+
+	allocation := uc.budget.Allocate(question, toHistory(kept), chunks)
+	if uc.history.Mode != HistoryPolicySummary || len(allocation.History) == len(conversation) {
+		return allocation
+	}
+
+	// Hold back room for the summary, so every message is either summarized or
+	// sent: the summary then fits next to the recent messages that remain
+	reserved := uc.budget
+	reserved.HistoryReserve = tokenizer.Count(historySummaryPrefix) + historySummaryTokens + 4
+	recent := reserved.Allocate(question, toHistory(kept), chunks).History
+	older := conversation[:len(conversation)-len(recent)]
+
+	summary, err := uc.summarizeOlder(ctx, sessionID, older)
+	if err != nil {
+		fmt.Printf("Warning: Failed to summarize earlier conversation: %v\n", err)
+		return allocation
+	}
+
+	history := append([]services.ChatMessage{{
+		Role:    "system",
+		Content: historySummaryPrefix + summary,
+	}}, recent...)
+	summarized := uc.budget.Allocate(question, history, chunks)
+	if len(summarized.History) < len(history) {
+		return allocation // The history share is too small to hold the summary
+	}
+	return summarized
+}
+
+// summarizeOlder returns a rolling summary of older messages. A session's
+// summary is extended with the messages that have aged out since, rather than
+// regenerated from the start.
+func (uc *ChatUseCase) summarizeOlder(ctx context.Context, sessionID string, older []*proto.ChatMessage) (string, error) {
+	uc.summariesMu.Lock()
+	cached, ok := uc.historySummaries[sessionID]
+	uc.summariesMu.Unlock()
+
+	start, previous := 0, ""
+	if ok && cached.covered <= len(older) && older[cached.covered-1].Id == cached.lastID {
+		if cached.covered == len(older) {
+			return cached.text, nil
+		}
+		start, previous = cached.covered, cached.text
+	}
+
+	summary, err := uc.aiService.SummarizeHistory(ctx, previous, toHistory(older[start:]))
+	if err != nil {
+		return "", err
+	}
+	text, _ := tokenizer.Truncate(summary, historySummaryTokens)
+
+	uc.summariesMu.Lock()
+	uc.historySummaries[sessionID] = historySummary{
+		covered: len(older),
+		lastID:  older[len(older)-1].Id,
+		text:    text,
+	}
+	uc.summariesMu.Unlock()
+
+	return text, nil
+}
+
+// forgetHistorySummary drops a session's rolling summary
+func (uc *ChatUseCase) forgetHistorySummary(sessionID string) {
+	uc.summariesMu.Lock()
+	delete(uc.historySummaries, sessionID)
+	uc.summariesMu.Unlock()
+}
+
+// toHistory converts stored messages to the typed history sent to AI providers
+func toHistory(messages []*proto.ChatMessage) []services.ChatMessage {
+	history := make([]services.ChatMessage, len(messages))
+	for i, msg := range messages {
+		history[i] = services.ChatMessage{Role: msg.Role, Content: msg.Content}
+	}
+	return history
+}
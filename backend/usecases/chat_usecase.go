@@ -1,36 +1,90 @@
 package usecases
 
 import (
-	"ai-pdf-assistant-backend/infrastructure/repositories"
 	"ai-pdf-assistant-backend/infrastructure/services"
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
 	"ai-pdf-assistant-backend/proto"
+	"context"
+	"encoding/json"
 	"fmt"
+	"sync"
 )
 
 // ChatUseCase handles chat-related business logic
 type ChatUseCase struct {
-	sessionRepo  *repositories.SessionRepository
+	sessions     *SessionManager
 	aiService    services.AIService
 	vectorSearch *services.VectorSearch
+	hybridSearch *services.HybridSearch
+	retrieval    services.RetrievalConfig // Deployment defaults; requests may override
+	budget       services.ContextBudget   // How the model's context window is shared out
+	history      HistoryPolicy            // Which earlier messages go with a question
+	checkAnswers bool                     // Ask the AI service whether answers are grounded, rather than a heuristic
+
+	summariesMu      sync.Mutex
+	historySummaries map[string]historySummary // Session ID -> rolling summary of older messages
 }
 
 // NewChatUseCase creates a new chat use case
 func NewChatUseCase(
-	sessionRepo *repositories.SessionRepository,
+	sessions *SessionManager,
 	aiService services.AIService,
 	vectorSearch *services.VectorSearch,
+	hybridSearch *services.HybridSearch,
+	retrieval services.RetrievalConfig,
+	budget services.ContextBudget,
+	history HistoryPolicy,
+	checkAnswers bool,
 ) *ChatUseCase {
-	return &ChatUseCase{
-		sessionRepo:  sessionRepo,
+	uc := &ChatUseCase{
+		sessions:     sessions,
 		aiService:    aiService,
 		vectorSearch: vectorSearch,
+		hybridSearch: hybridSearch,
+		retrieval:    retrieval,
+		budget:       budget,
+		history:      history,
+		checkAnswers: checkAnswers,
+
+		historySummaries: make(map[string]historySummary),
 	}
+
+	// Rolling summaries go with their session; IDs are never reused
+	sessions.OnRemove(uc.forgetHistorySummary)
+	return uc
 }
 
 // AskQuestion processes a chat question and returns an answer
-func (uc *ChatUseCase) AskQuestion(req *proto.ChatRequest) (*proto.ChatResponse, error) {
-	// Get session
-	session, err := uc.sessionRepo.Get(req.SessionId)
+func (uc *ChatUseCase) AskQuestion(ctx context.Context, req *proto.ChatRequest) (*proto.ChatResponse, error) {
+	return uc.answer(ctx, req, func(ctx context.Context, docContext string, history []services.ChatMessage) (string, error) {
+		return uc.aiService.AnswerQuestion(ctx, docContext, req.Message, history)
+	})
+}
+
+// StreamQuestion processes a chat question, passing answer tokens to onToken as the
+// AI service generates them. The returned response holds the complete answer.
+func (uc *ChatUseCase) StreamQuestion(ctx context.Context, req *proto.ChatRequest, onToken services.TokenHandler) (*proto.ChatResponse, error) {
+	return uc.answer(ctx, req, func(ctx context.Context, docContext string, history []services.ChatMessage) (string, error) {
+		return uc.aiService.StreamAnswer(ctx, docContext, req.Message, history, onToken)
+	})
+}
+
+// answer runs retrieval for a question, asks generate for the answer and records it in the session
+func (uc *ChatUseCase) answer(ctx context.Context, req *proto.ChatRequest, generate func(ctx context.Context, docContext string, history []services.ChatMessage) (string, error)) (*proto.ChatResponse, error) {
+	// Apply per-request retrieval overrides on top of the deployment defaults
+	retrieval := uc.retrievalConfig(req)
+	if err := uc.hybridSearch.Validate(retrieval); err != nil {
+		return &proto.ChatResponse{
+			Status: proto.Status_STATUS_ERROR,
+			Error: &proto.Error{
+				Code:    "INVALID_RETRIEVAL_CONFIG",
+				Message: err.Error(),
+			},
+		}, nil
+	}
+
+	// Get session, restoring it from the database if it is no longer in memory
+	session, err := uc.sessions.Get(ctx, req.SessionId)
 	if err != nil {
 		return &proto.ChatResponse{
 			Status: proto.Status_STATUS_NOT_FOUND,
@@ -41,12 +95,35 @@ func (uc *ChatUseCase) AskQuestion(req *proto.ChatRequest) (*proto.ChatResponse,
 		}, nil
 	}
 
+	// Collect chunks from ALL documents in the session
+	documents := session.Documents
+	if len(documents) == 0 && session.Document != nil {
+		documents = []*proto.Document{session.Document}
+	}
+	var allChunks []*proto.Chunk
+	for _, doc := range documents {
+		allChunks = append(allChunks, doc.Chunks...)
+	}
+
+	// Uploads are processed in the background; there is nothing to search until one is ready
+	if len(allChunks) == 0 {
+		return &proto.ChatResponse{
+			Status: proto.Status_STATUS_PROCESSING,
+			Error: &proto.Error{
+				Code:    "DOCUMENT_NOT_READY",
+				Message: "The document is still being processed, please try again shortly",
+			},
+		}, nil
+	}
+
 	// Add user message to session
 	userMessage := &proto.ChatMessage{
 		Role:    "user",
 		Content: req.Message,
 	}
-	if err := uc.sessionRepo.AddMessage(req.SessionId, userMessage); err != nil {
+	// Earlier messages are copied under the cache lock, before this one is added
+	earlier, err := uc.sessions.AddMessage(req.SessionId, userMessage, nil)
+	if err != nil {
 		return &proto.ChatResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
@@ -56,23 +133,36 @@ func (uc *ChatUseCase) AskQuestion(req *proto.ChatRequest) (*proto.ChatResponse,
 		}, nil
 	}
 
-	// Collect chunks from ALL documents in the session
-	var allChunks []*proto.Chunk
-	if len(session.Documents) > 0 {
-		for _, doc := range session.Documents {
-			allChunks = append(allChunks, doc.Chunks...)
-		}
-	} else if session.Document != nil {
-		allChunks = session.Document.Chunks
+	// Resolve follow-ups like "what about the second one?" into a query that stands on its own
+	query := req.Message
+	if retrieval.RewriteQuery && len(earlier) > 0 {
+		query = uc.searchQuery(ctx, req.Message, earlier)
+	}
+	rewrittenQuery := ""
+	if query != req.Message {
+		rewrittenQuery = query
+	}
+
+	// Find the most relevant chunks for context by fusing keyword and semantic rankings
+	scoredChunks := uc.hybridSearch.Search(ctx, allChunks, query, retrieval)
+	relevantChunks := make([]*proto.Chunk, len(scoredChunks))
+	for i, s := range scoredChunks {
+		relevantChunks[i] = s.Chunk
 	}
 
-	// Find the most relevant chunks for context (topK=20 for good coverage)
-	relevantChunks := uc.vectorSearch.FindRelevantChunks(allChunks, req.Message, 20)
+	// Share the context window between history and chunks, dropping the lowest-ranked chunks first
+	allocation := uc.conversationHistory(ctx, req.SessionId, earlier, req.Message, relevantChunks)
+	history := allocation.History
+	relevantChunks = allocation.Chunks
+	scoredChunks = scoredChunks[:len(relevantChunks)]
+	if allocation.DroppedChunks > 0 {
+		fmt.Printf("Warning: Dropped %d lowest-ranked chunks to fit the context window\n", allocation.DroppedChunks)
+	}
 
 	// Build context from relevant chunks instead of all chunks to stay within token limits
-	context := uc.vectorSearch.BuildContext(relevantChunks)
-	if context == "" {
-		// Fallback: if no relevant chunks matched, use first ~15000 chars of document text
+	docContext := uc.vectorSearch.BuildContext(relevantChunks)
+	if docContext == "" {
+		// Fallback: if no relevant chunks matched, use as much of the document text as fits
 		var fullText string
 		if len(session.Documents) > 0 {
 			for _, doc := range session.Documents {
@@ -81,77 +171,136 @@ func (uc *ChatUseCase) AskQuestion(req *proto.ChatRequest) (*proto.ChatResponse,
 		} else if session.Document != nil {
 			fullText = session.Document.Text
 		}
-		if len(fullText) > 15000 {
-			fullText = fullText[:15000] + "\n... [truncated]"
-		}
-		context = "Document Context:\n\n" + fullText
-	}
-
-	// Use the same relevantChunks for citations
-
-	// Build conversation history (exclude the current user message we just added)
-	history := make([]string, 0)
-	for i, msg := range session.Messages {
-		// Skip the last message (the one we just added)
-		if i == len(session.Messages)-1 {
-			continue
-		}
-		if msg.Role == "user" {
-			history = append(history, "User: "+msg.Content)
-		} else if msg.Role == "assistant" {
-			history = append(history, "Assistant: "+msg.Content)
+		if truncated, cut := tokenizer.Truncate(fullText, allocation.ContextTokens-tokenizer.Count("Document Context:\n\n... [truncated]")); cut {
+			fullText = truncated + "\n... [truncated]"
 		}
+		docContext = "Document Context:\n\n" + fullText
 	}
 
-	// Get AI response
-	answer, answerFound, err := uc.aiService.AnswerQuestion(context, req.Message, history)
+	// Get AI response, noting which provider in the fallback chain answered
+	aiCtx, answeredBy := services.WithAnswerSource(ctx)
+	answer, err := generate(aiCtx, docContext, history)
 	if err != nil {
 		return &proto.ChatResponse{
 			Status: proto.Status_STATUS_ERROR,
-			Error: &proto.Error{
-				Code:    "AI_SERVICE_ERROR",
-				Message: fmt.Sprintf("Failed to get AI response: %v", err),
-			},
+			Error:  aiServiceError(ctx, err, "get AI response"),
 		}, nil
 	}
 
+	provider := answeredBy.Last(uc.aiService.ModelInfo())
+
+	// Judge whether the retrieved context supports the answer
+	grounding := uc.grounding(ctx, docContext, req.Message, answer)
+
+	// Cite the passages the answer's [n] markers point to, so the viewer can jump to them
+	sentences := services.AttributeAnswer(answer, len(relevantChunks))
+	citations := services.CitedSources(uc.vectorSearch.GetCitations(relevantChunks, documents, query), sentences)
+
 	// Add AI response to session
 	aiMessage := &proto.ChatMessage{
 		Role:    "assistant",
 		Content: answer,
 	}
-	if err := uc.sessionRepo.AddMessage(req.SessionId, aiMessage); err != nil {
+	citationsJSON, _ := json.Marshal(citations)
+	if _, err := uc.sessions.AddMessage(req.SessionId, aiMessage, citationsJSON); err != nil {
 		// Log error but don't fail the request
 		fmt.Printf("Warning: Failed to store AI message: %v\n", err)
 	}
 
-	// Extract relevant chunk texts for transparency
-	relevantChunkTexts := make([]string, len(relevantChunks))
-	for i, chunk := range relevantChunks {
+	// Extract relevant chunk texts and scores for transparency
+	relevantChunkTexts := make([]string, len(scoredChunks))
+	retrievedChunks := make([]*proto.RetrievedChunk, len(scoredChunks))
+	for i, s := range scoredChunks {
 		// Limit chunk text length for response
-		chunkText := chunk.Text
-		if len(chunkText) > 200 {
-			chunkText = chunkText[:200] + "..."
+		chunkText, cut := tokenizer.Prefix(s.Chunk.Text, 200)
+		if cut {
+			chunkText += "..."
 		}
 		relevantChunkTexts[i] = chunkText
+		retrievedChunks[i] = &proto.RetrievedChunk{
+			ChunkId:       s.Chunk.Id,
+			PageNumber:    s.Chunk.PageNumber,
+			EndPageNumber: s.Chunk.EndPageNumber,
+			Score:         s.Score,
+			Retriever:     s.Retriever,
+			Text:          chunkText,
+		}
 	}
 
-	// Get citations for the relevant chunks
-	citations := uc.vectorSearch.GetCitations(relevantChunks)
-
 	return &proto.ChatResponse{
-		Status:         proto.Status_STATUS_SUCCESS,
-		Response:       answer,
-		SessionId:      req.SessionId,
-		RelevantChunks: relevantChunkTexts,
-		AnswerFound:    answerFound,
-		Citations:      citations,
+		Status:          proto.Status_STATUS_SUCCESS,
+		Response:        answer,
+		SessionId:       req.SessionId,
+		RelevantChunks:  relevantChunkTexts,
+		RetrievedChunks: retrievedChunks,
+		AnswerFound:     grounding.AnswerFound,
+		Confidence:      grounding.Confidence,
+		Citations:       citations,
+		Sentences:       sentences,
+		Provider:        provider.Provider,
+		Model:           provider.Model,
+		RewrittenQuery:  rewrittenQuery,
 	}, nil
 }
 
+// retrievalConfig merges per-request retrieval settings into the deployment defaults
+func (uc *ChatUseCase) retrievalConfig(req *proto.ChatRequest) services.RetrievalConfig {
+	cfg := uc.retrieval
+	if req.TopK != 0 {
+		cfg.TopK = int(req.TopK)
+	}
+	if req.KeywordWeight != nil {
+		cfg.KeywordWeight = *req.KeywordWeight
+	}
+	if req.DenseWeight != nil {
+		cfg.DenseWeight = *req.DenseWeight
+	}
+	if req.RewriteQuery != nil {
+		cfg.RewriteQuery = *req.RewriteQuery
+	}
+	return cfg
+}
+
+// grounding checks the answer against the document context, falling back to a
+// heuristic verdict if the check is off or the AI service fails
+func (uc *ChatUseCase) grounding(ctx context.Context, docContext string, question string, answer string) services.Grounding {
+	if uc.checkAnswers {
+		grounding, err := uc.aiService.CheckGrounding(ctx, docContext, question, answer)
+		if err == nil {
+			return grounding
+		}
+		if ctx.Err() == nil {
+			fmt.Printf("Warning: Failed to check answer grounding: %v\n", err)
+		}
+	}
+	return services.HeuristicGrounding(docContext, answer)
+}
+
+// searchQuery rewrites a question into a standalone search query using the
+// conversation so far, falling back to a heuristic rewrite if the AI service fails
+func (uc *ChatUseCase) searchQuery(ctx context.Context, question string, earlier []*proto.ChatMessage) string {
+	var conversation []*proto.ChatMessage
+	for _, msg := range earlier {
+		if msg.Role == "user" || msg.Role == "assistant" {
+			conversation = append(conversation, msg)
+		}
+	}
+	history := toHistory(conversation)
+
+	query, err := uc.aiService.RewriteQuery(ctx, question, history)
+	if err != nil {
+		if ctx.Err() != nil {
+			return question
+		}
+		fmt.Printf("Warning: Failed to rewrite search query: %v\n", err)
+		return services.HeuristicRewriteQuery(question, history)
+	}
+	return query
+}
+
 // GetHistory retrieves chat history for a session
-func (uc *ChatUseCase) GetHistory(sessionID string) (*proto.HistoryResponse, error) {
-	session, err := uc.sessionRepo.Get(sessionID)
+func (uc *ChatUseCase) GetHistory(ctx context.Context, sessionID string) (*proto.HistoryResponse, error) {
+	session, err := uc.sessions.Get(ctx, sessionID)
 	if err != nil {
 		return &proto.HistoryResponse{
 			Status: proto.Status_STATUS_NOT_FOUND,
@@ -169,8 +318,9 @@ func (uc *ChatUseCase) GetHistory(sessionID string) (*proto.HistoryResponse, err
 }
 
 // ClearSession clears all messages in a session
-func (uc *ChatUseCase) ClearSession(sessionID string) (*proto.ClearSessionResponse, error) {
-	err := uc.sessionRepo.ClearMessages(sessionID)
+func (uc *ChatUseCase) ClearSession(ctx context.Context, sessionID string) (*proto.ClearSessionResponse, error) {
+	uc.forgetHistorySummary(sessionID)
+	err := uc.sessions.ClearMessages(ctx, sessionID)
 	if err != nil {
 		return &proto.ClearSessionResponse{
 			Status: proto.Status_STATUS_NOT_FOUND,
@@ -0,0 +1,123 @@
+package usecases
+
+import (
+	"ai-pdf-assistant-backend/infrastructure/repositories"
+	"ai-pdf-assistant-backend/proto"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// StartWorkers starts a fixed pool of goroutines that process queued uploads.
+// Workers stop when ctx is cancelled; jobs in flight and still queued are marked failed.
+func (uc *PDFUseCase) StartWorkers(ctx context.Context, workers int) {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					uc.failQueued()
+					return
+				case job := <-uc.queue:
+					uc.processJob(ctx, job)
+				}
+			}
+		}()
+	}
+}
+
+// failQueued marks the jobs left in the queue as failed, so their status
+// polls do not report them as queued forever
+func (uc *PDFUseCase) failQueued() {
+	for {
+		select {
+		case job := <-uc.queue:
+			log.Printf("Not processing %s: server is shutting down", job.Filename)
+			uc.jobRepo.SetState(job.DocumentID, repositories.JobFailed, "server shut down before processing")
+		default:
+			return
+		}
+	}
+}
+
+// enqueue registers a job for the document and hands it to the worker pool
+func (uc *PDFUseCase) enqueue(sessionID string, doc *proto.Document, filePath string) error {
+	job := &repositories.IngestionJob{
+		DocumentID: doc.Id,
+		SessionID:  sessionID,
+		Filename:   doc.Filename,
+		FilePath:   filePath,
+	}
+	uc.jobRepo.Create(job)
+	return uc.submit(job)
+}
+
+// requeue processes a stored document again from its uploaded file. It is
+// for documents with neither content nor a job: processing was cut short by
+// a restart, or failed long enough ago that its job has expired.
+func (uc *PDFUseCase) requeue(record *repositories.DBDocument) error {
+	if record.FilePath == "" {
+		return fmt.Errorf("processing was interrupted and the uploaded file was not kept")
+	}
+	if _, err := os.Stat(record.FilePath); err != nil {
+		return fmt.Errorf("processing was interrupted and the uploaded file is no longer available")
+	}
+
+	job := &repositories.IngestionJob{
+		DocumentID: record.ID,
+		SessionID:  record.SessionID,
+		Filename:   record.Filename,
+		FilePath:   record.FilePath,
+	}
+	if !uc.jobRepo.CreateIfMissing(job) {
+		return nil // Another status request queued it first
+	}
+	log.Printf("Re-queueing %s: it has no stored content and no job", record.Filename)
+	uc.submit(job) // A full queue marks the job failed, which the status reports
+	return nil
+}
+
+// submit hands a registered job to the worker pool
+func (uc *PDFUseCase) submit(job *repositories.IngestionJob) error {
+	select {
+	case uc.queue <- job:
+		return nil
+	default:
+		uc.jobRepo.SetState(job.DocumentID, repositories.JobFailed, "processing queue is full")
+		return fmt.Errorf("too many uploads are being processed, please try again shortly")
+	}
+}
+
+// processJob parses, chunks and indexes a queued PDF, then swaps the
+// finished document into its session
+func (uc *PDFUseCase) processJob(ctx context.Context, job *repositories.IngestionJob) {
+	uc.jobRepo.SetState(job.DocumentID, repositories.JobProcessing, "")
+
+	doc, err := uc.pdfService.ProcessPDF(ctx, job.DocumentID, job.FilePath, job.Filename, func(pagesDone int, pagesTotal int) {
+		uc.jobRepo.SetProgress(job.DocumentID, pagesDone, pagesTotal)
+	})
+	if err != nil {
+		log.Printf("Failed to process %s: %v", job.Filename, err)
+		uc.jobRepo.SetState(job.DocumentID, repositories.JobFailed, err.Error())
+		return
+	}
+
+	// Store text and chunks, then swap the finished document into its session
+	if err := uc.sessions.ReplaceDocument(job.SessionID, doc); err != nil {
+		reason := err.Error()
+		if errors.Is(err, repositories.ErrNotFound) {
+			reason = "document was removed while processing"
+		}
+		log.Printf("Failed to store %s: %s", job.Filename, reason)
+		uc.vectorSearch.RemoveDocument(job.DocumentID)
+		uc.jobRepo.SetState(job.DocumentID, repositories.JobFailed, reason)
+		return
+	}
+
+	// Build the search index only once the document is known to still exist
+	uc.vectorSearch.IndexDocument(doc)
+
+	uc.jobRepo.SetState(job.DocumentID, repositories.JobReady, "")
+}
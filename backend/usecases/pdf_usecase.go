@@ -4,141 +4,191 @@ import (
 	"ai-pdf-assistant-backend/infrastructure/repositories"
 	"ai-pdf-assistant-backend/infrastructure/services"
 	"ai-pdf-assistant-backend/proto"
+	"context"
+	"errors"
 	"fmt"
+
+	"github.com/google/uuid"
 )
 
 // PDFUseCase handles PDF-related business logic
 type PDFUseCase struct {
-	docRepo     *repositories.DocumentRepository
-	sessionRepo *repositories.SessionRepository
-	pdfService  *services.PDFService
+	documents    repositories.DocumentStore
+	sessions     *SessionManager
+	jobRepo      *repositories.JobRepository
+	pdfService   *services.PDFService
+	vectorSearch *services.VectorSearch
+	queue        chan *repositories.IngestionJob
 }
 
-// NewPDFUseCase creates a new PDF use case
+// NewPDFUseCase creates a new PDF use case. At most queueSize uploads can
+// wait for processing; call StartWorkers to begin processing them.
 func NewPDFUseCase(
-	docRepo *repositories.DocumentRepository,
-	sessionRepo *repositories.SessionRepository,
+	documents repositories.DocumentStore,
+	sessions *SessionManager,
+	jobRepo *repositories.JobRepository,
 	pdfService *services.PDFService,
+	vectorSearch *services.VectorSearch,
+	queueSize int,
 ) *PDFUseCase {
 	return &PDFUseCase{
-		docRepo:     docRepo,
-		sessionRepo: sessionRepo,
-		pdfService:  pdfService,
+		documents:    documents,
+		sessions:     sessions,
+		jobRepo:      jobRepo,
+		pdfService:   pdfService,
+		vectorSearch: vectorSearch,
+		queue:        make(chan *repositories.IngestionJob, queueSize),
 	}
 }
 
-// UploadPDF processes and stores a PDF file
-func (uc *PDFUseCase) UploadPDF(filePath string, filename string) (*proto.UploadResponse, error) {
-	// Process PDF
-	doc, err := uc.pdfService.ProcessPDF(filePath, filename)
-	if err != nil {
-		return &proto.UploadResponse{
-			Status: proto.Status_STATUS_ERROR,
-			Error: &proto.Error{
-				Code:    "PDF_PROCESSING_ERROR",
-				Message: fmt.Sprintf("Failed to process PDF: %v", err),
-			},
-		}, nil
+// UploadPDF registers a PDF in a new session and queues it for processing.
+// The returned document is a placeholder until its job is ready. userID is
+// empty for anonymous uploads.
+func (uc *PDFUseCase) UploadPDF(filePath string, filename string, userID string) (*proto.UploadResponse, error) {
+	doc := &proto.Document{
+		Id:       uuid.New().String(),
+		Filename: filename,
 	}
 
-	// Store document
-	if err := uc.docRepo.Store(doc); err != nil {
+	// Create session
+	session, err := uc.sessions.Create(userID, doc, filePath)
+	if err != nil {
 		return &proto.UploadResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
-				Code:    "STORAGE_ERROR",
-				Message: fmt.Sprintf("Failed to store document: %v", err),
+				Code:    "SESSION_ERROR",
+				Message: fmt.Sprintf("Failed to create session: %v", err),
 			},
 		}, nil
 	}
 
-	// Create session
-	session, err := uc.sessionRepo.Create(doc.Id, doc)
-	if err != nil {
+	// Queue parsing, chunking and indexing
+	if err := uc.enqueue(session.Id, doc, filePath); err != nil {
+		uc.sessions.Delete(session.Id)
 		return &proto.UploadResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
-				Code:    "SESSION_ERROR",
-				Message: fmt.Sprintf("Failed to create session: %v", err),
+				Code:    "QUEUE_FULL",
+				Message: err.Error(),
 			},
 		}, nil
 	}
 
 	return &proto.UploadResponse{
-		Status:    proto.Status_STATUS_SUCCESS,
+		Status:    proto.Status_STATUS_PROCESSING,
 		Document:  doc,
 		SessionId: session.Id,
 	}, nil
 }
 
-// GetDocumentStatus retrieves document status
+// GetDocumentStatus retrieves document processing status
 func (uc *PDFUseCase) GetDocumentStatus(documentID string) (*proto.StatusResponse, error) {
-	doc, err := uc.docRepo.Get(documentID)
+	doc, err := uc.documents.GetContent(documentID)
+	var record *repositories.DBDocument
+	if errors.Is(err, repositories.ErrNotFound) {
+		// Not processed yet; report what the upload record knows
+		if record, err = uc.documents.Get(documentID); err == nil {
+			doc = &proto.Document{Id: record.ID, Filename: record.Filename}
+		}
+	}
 	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return &proto.StatusResponse{
+				Status: proto.Status_STATUS_NOT_FOUND,
+				Error: &proto.Error{
+					Code:    "NOT_FOUND",
+					Message: fmt.Sprintf("Document not found: %s", documentID),
+				},
+			}, nil
+		}
 		return &proto.StatusResponse{
-			Status: proto.Status_STATUS_NOT_FOUND,
+			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
-				Code:    "NOT_FOUND",
-				Message: fmt.Sprintf("Document not found: %v", err),
+				Code:    "STORAGE_ERROR",
+				Message: fmt.Sprintf("Failed to load document: %v", err),
 			},
 		}, nil
 	}
 
+	// Jobs only live as long as the process, and finished ones expire;
+	// without one, stored content means processing finished, and a document
+	// without content is processed again
+	ingestion := &proto.IngestionStatus{
+		State:      repositories.JobReady,
+		PagesDone:  doc.Pages,
+		PagesTotal: doc.Pages,
+	}
+	if _, err := uc.jobRepo.Get(documentID); err != nil && record != nil {
+		if err := uc.requeue(record); err != nil {
+			ingestion = &proto.IngestionStatus{
+				State: repositories.JobFailed,
+				Error: err.Error(),
+			}
+		}
+	}
+	if job, err := uc.jobRepo.Get(documentID); err == nil {
+		ingestion = &proto.IngestionStatus{
+			State:      job.State,
+			PagesDone:  int32(job.PagesDone),
+			PagesTotal: int32(job.PagesTotal),
+			Error:      job.Error,
+		}
+	}
+
 	return &proto.StatusResponse{
-		Status:   proto.Status_STATUS_SUCCESS,
-		Document: doc,
+		Status:    proto.Status_STATUS_SUCCESS,
+		Document:  doc,
+		Ingestion: ingestion,
 	}, nil
 }
 
-// AddDocumentToSession adds a document to an existing session
-func (uc *PDFUseCase) AddDocumentToSession(sessionID string, filePath string, filename string) (*proto.UploadResponse, error) {
-	// Process PDF
-	doc, err := uc.pdfService.ProcessPDF(filePath, filename)
-	if err != nil {
-		return &proto.UploadResponse{
-			Status: proto.Status_STATUS_ERROR,
-			Error: &proto.Error{
-				Code:    "PDF_PROCESSING_ERROR",
-				Message: fmt.Sprintf("Failed to process PDF: %v", err),
-			},
-		}, nil
+// AddDocumentToSession adds a document to an existing session and queues it for processing
+func (uc *PDFUseCase) AddDocumentToSession(ctx context.Context, sessionID string, filePath string, filename string) (*proto.UploadResponse, error) {
+	doc := &proto.Document{
+		Id:       uuid.New().String(),
+		Filename: filename,
 	}
 
-	// Store document
-	if err := uc.docRepo.Store(doc); err != nil {
+	// Add to existing session
+	if err := uc.sessions.AddDocument(ctx, sessionID, doc, filePath); err != nil {
 		return &proto.UploadResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
-				Code:    "STORAGE_ERROR",
-				Message: fmt.Sprintf("Failed to store document: %v", err),
+				Code:    "SESSION_ERROR",
+				Message: fmt.Sprintf("Failed to add document to session: %v", err),
 			},
 		}, nil
 	}
 
-	// Add to existing session
-	if err := uc.sessionRepo.AddDocument(sessionID, doc); err != nil {
+	// Queue parsing, chunking and indexing
+	if err := uc.enqueue(sessionID, doc, filePath); err != nil {
+		uc.sessions.RemoveDocument(ctx, sessionID, doc.Id)
 		return &proto.UploadResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
-				Code:    "SESSION_ERROR",
-				Message: fmt.Sprintf("Failed to add document to session: %v", err),
+				Code:    "QUEUE_FULL",
+				Message: err.Error(),
 			},
 		}, nil
 	}
 
 	return &proto.UploadResponse{
-		Status:    proto.Status_STATUS_SUCCESS,
+		Status:    proto.Status_STATUS_PROCESSING,
 		Document:  doc,
 		SessionId: sessionID,
 	}, nil
 }
 
 // GetSessionDocuments returns all documents in a session
-func (uc *PDFUseCase) GetSessionDocuments(sessionID string) ([]*proto.Document, error) {
-	return uc.sessionRepo.GetDocuments(sessionID)
+func (uc *PDFUseCase) GetSessionDocuments(ctx context.Context, sessionID string) ([]*proto.Document, error) {
+	session, err := uc.sessions.Get(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return session.Documents, nil
 }
 
 // RemoveDocumentFromSession removes a document from a session
-func (uc *PDFUseCase) RemoveDocumentFromSession(sessionID string, documentID string) error {
-	return uc.sessionRepo.RemoveDocument(sessionID, documentID)
+func (uc *PDFUseCase) RemoveDocumentFromSession(ctx context.Context, sessionID string, documentID string) error {
+	return uc.sessions.RemoveDocument(ctx, sessionID, documentID)
 }
@@ -0,0 +1,406 @@
+package usecases
+
+import (
+	"ai-pdf-assistant-backend/infrastructure/repositories"
+	"ai-pdf-assistant-backend/infrastructure/services"
+	"ai-pdf-assistant-backend/proto"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// SessionManager keeps the session cache and the stores in step. Writes go to
+// the stores first; sessions missing from the cache are rebuilt from them.
+type SessionManager struct {
+	cache        *repositories.SessionCache
+	sessions     repositories.SessionStore
+	documents    repositories.DocumentStore
+	messages     repositories.MessageStore
+	summaries    repositories.SummaryStore
+	jobs         *repositories.JobRepository
+	pdfService   *services.PDFService
+	vectorSearch *services.VectorSearch
+	loads        singleflight.Group // Rebuilds a session once however many requests miss it
+	onRemove     []func(sessionID string)
+}
+
+// NewSessionManager creates a new session manager
+func NewSessionManager(
+	cache *repositories.SessionCache,
+	sessions repositories.SessionStore,
+	documents repositories.DocumentStore,
+	messages repositories.MessageStore,
+	summaries repositories.SummaryStore,
+	jobs *repositories.JobRepository,
+	pdfService *services.PDFService,
+	vectorSearch *services.VectorSearch,
+) *SessionManager {
+	return &SessionManager{
+		cache:        cache,
+		sessions:     sessions,
+		documents:    documents,
+		messages:     messages,
+		summaries:    summaries,
+		jobs:         jobs,
+		pdfService:   pdfService,
+		vectorSearch: vectorSearch,
+	}
+}
+
+// Create starts a new session around an uploaded document. userID may be
+// empty for anonymous uploads.
+func (m *SessionManager) Create(userID string, doc *proto.Document, filePath string) (*proto.ChatSession, error) {
+	now := time.Now()
+	session := &proto.ChatSession{
+		Id:           uuid.New().String(),
+		DocumentId:   doc.Id,
+		Document:     doc,
+		Documents:    []*proto.Document{doc},
+		Messages:     []*proto.ChatMessage{},
+		CreatedAt:    now.Unix(),
+		LastActivity: now.Unix(),
+	}
+
+	if err := m.sessions.Create(&repositories.DBSession{
+		ID:           session.Id,
+		UserID:       userID,
+		Title:        doc.Filename,
+		CreatedAt:    now,
+		LastActivity: now,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
+
+	if err := m.createDocument(session.Id, doc, filePath); err != nil {
+		m.sessions.Delete(session.Id)
+		return nil, err
+	}
+
+	return m.cache.Put(session), nil
+}
+
+// Get returns the cached session, rebuilding it from the stores if needed.
+// Concurrent misses for a session share one rebuild; other sessions are not
+// held up by it.
+func (m *SessionManager) Get(ctx context.Context, sessionID string) (*proto.ChatSession, error) {
+	if session, err := m.cache.Get(sessionID); err == nil {
+		return session, nil
+	}
+
+	// The rebuild outlives a caller that gives up, as others may be waiting on it
+	loaded := m.loads.DoChan(sessionID, func() (interface{}, error) {
+		return m.load(context.WithoutCancel(ctx), sessionID)
+	})
+	select {
+	case result := <-loaded:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*proto.ChatSession), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load rebuilds a session from the stores and caches it
+func (m *SessionManager) load(ctx context.Context, sessionID string) (*proto.ChatSession, error) {
+	// Another request may have rebuilt it since the cache was checked
+	if session, err := m.cache.Get(sessionID); err == nil {
+		return session, nil
+	}
+
+	dbSession, err := m.sessions.Get(sessionID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotFound) {
+			return nil, fmt.Errorf("session not found: %s", sessionID)
+		}
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	session, pending, err := m.rebuild(ctx, dbSession)
+	if err != nil {
+		return nil, err
+	}
+	session = m.cache.Put(session)
+
+	// A job may have stored its document between the rebuild reading the
+	// store and the session reaching the cache, missing the cache update
+	for _, doc := range pending {
+		if stored, err := m.documents.GetContent(doc.Id); err == nil {
+			m.cache.ReplaceDocument(sessionID, stored)
+		}
+	}
+
+	return session, nil
+}
+
+// AddDocument adds an uploaded document to an existing session
+func (m *SessionManager) AddDocument(ctx context.Context, sessionID string, doc *proto.Document, filePath string) error {
+	if _, err := m.Get(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if err := m.createDocument(sessionID, doc, filePath); err != nil {
+		return err
+	}
+
+	if err := m.cache.AddDocument(sessionID, doc); err != nil {
+		m.documents.Delete(doc.Id)
+		return err
+	}
+
+	m.invalidateSummaries(sessionID)
+	return nil
+}
+
+// ReplaceDocument stores a processed document and swaps it into its session
+func (m *SessionManager) ReplaceDocument(sessionID string, doc *proto.Document) error {
+	if err := m.documents.SaveContent(doc); err != nil {
+		return fmt.Errorf("failed to store document content: %w", err)
+	}
+
+	// Sessions evicted meanwhile pick up the stored content when rebuilt
+	m.cache.ReplaceDocument(sessionID, doc)
+	return nil
+}
+
+// RemoveDocument removes a document from a session
+func (m *SessionManager) RemoveDocument(ctx context.Context, sessionID string, documentID string) error {
+	if _, err := m.Get(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if err := m.cache.RemoveDocument(sessionID, documentID); err != nil {
+		return err
+	}
+
+	if err := m.documents.Delete(documentID); err != nil {
+		return fmt.Errorf("failed to delete document: %w", err)
+	}
+	m.vectorSearch.RemoveDocument(documentID)
+
+	m.invalidateSummaries(sessionID)
+	return nil
+}
+
+// AddMessage records a chat message and returns the session's earlier
+// messages. citations may be nil.
+func (m *SessionManager) AddMessage(sessionID string, message *proto.ChatMessage, citations json.RawMessage) ([]*proto.ChatMessage, error) {
+	now := time.Now()
+	if message.Id == "" {
+		message.Id = uuid.New().String()
+	}
+	if message.Timestamp == 0 {
+		message.Timestamp = now.Unix()
+	}
+
+	if err := m.messages.Create(&repositories.DBMessage{
+		ID:        message.Id,
+		SessionID: sessionID,
+		Role:      message.Role,
+		Content:   message.Content,
+		Citations: citations,
+		CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+	if err := m.sessions.Touch(sessionID, now); err != nil {
+		fmt.Printf("Warning: Failed to record session activity: %v\n", err)
+	}
+
+	return m.cache.AddMessage(sessionID, message)
+}
+
+// ClearMessages deletes a session's chat history
+func (m *SessionManager) ClearMessages(ctx context.Context, sessionID string) error {
+	if _, err := m.Get(ctx, sessionID); err != nil {
+		return err
+	}
+
+	if err := m.messages.DeleteBySession(sessionID); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	return m.cache.ClearMessages(sessionID)
+}
+
+// Delete removes a session with its documents and messages
+func (m *SessionManager) Delete(sessionID string) error {
+	docs, err := m.documents.ListBySession(sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+	for _, doc := range docs {
+		if err := m.documents.Delete(doc.ID); err != nil {
+			return fmt.Errorf("failed to delete document: %w", err)
+		}
+		m.vectorSearch.RemoveDocument(doc.ID)
+	}
+
+	if err := m.messages.DeleteBySession(sessionID); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	if err := m.summaries.DeleteBySession(sessionID); err != nil {
+		return fmt.Errorf("failed to delete summaries: %w", err)
+	}
+	if err := m.sessions.Delete(sessionID); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	m.cache.Delete(sessionID)
+	m.removed(sessionID)
+	return nil
+}
+
+// CleanupInactive evicts sessions inactive for longer than duration from the
+// cache. Anonymous sessions are deleted outright since nobody can list them
+// again; sessions owned by a user stay stored and are rebuilt on next use.
+func (m *SessionManager) CleanupInactive(duration time.Duration) int {
+	evicted := m.cache.EvictInactive(duration)
+
+	for _, id := range evicted {
+		m.removed(id)
+		session, err := m.sessions.Get(id)
+		if err != nil || session.UserID != "" {
+			continue
+		}
+		if err := m.Delete(id); err != nil {
+			fmt.Printf("Warning: Failed to delete inactive session %s: %v\n", id, err)
+		}
+	}
+
+	return len(evicted)
+}
+
+// OnRemove registers fn to run when a session leaves the cache, because it was
+// deleted or evicted. Register callbacks before the manager is in use.
+func (m *SessionManager) OnRemove(fn func(sessionID string)) {
+	m.onRemove = append(m.onRemove, fn)
+}
+
+// removed runs the OnRemove callbacks for a session
+func (m *SessionManager) removed(sessionID string) {
+	for _, fn := range m.onRemove {
+		fn(sessionID)
+	}
+}
+
+// invalidateSummaries drops a session's cached summaries after its documents change
+func (m *SessionManager) invalidateSummaries(sessionID string) {
+	if err := m.summaries.DeleteBySession(sessionID); err != nil {
+		fmt.Printf("Warning: Failed to invalidate summaries for session %s: %v\n", sessionID, err)
+	}
+}
+
+// createDocument stores the record for a newly uploaded document
+func (m *SessionManager) createDocument(sessionID string, doc *proto.Document, filePath string) error {
+	if err := m.documents.Create(&repositories.DBDocument{
+		ID:         doc.Id,
+		SessionID:  sessionID,
+		Filename:   doc.Filename,
+		FilePath:   filePath,
+		UploadedAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to store document: %w", err)
+	}
+	return nil
+}
+
+// rebuild reloads a stored session's documents and messages. Documents whose
+// ingestion job is still running are returned as pending placeholders, which
+// the job replaces once it is done.
+func (m *SessionManager) rebuild(ctx context.Context, dbSession *repositories.DBSession) (*proto.ChatSession, []*proto.Document, error) {
+	dbDocs, err := m.documents.ListBySession(dbSession.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session documents: %w", err)
+	}
+
+	session := &proto.ChatSession{
+		Id:        dbSession.ID,
+		Documents: []*proto.Document{},
+		Messages:  []*proto.ChatMessage{},
+		CreatedAt: dbSession.CreatedAt.Unix(),
+	}
+
+	var pending []*proto.Document
+	for _, dbDoc := range dbDocs {
+		if m.jobs.Active(dbDoc.ID) {
+			doc := &proto.Document{Id: dbDoc.ID, Filename: dbDoc.Filename}
+			session.Documents = append(session.Documents, doc)
+			pending = append(pending, doc)
+			continue
+		}
+
+		doc, err := m.restoreDocument(ctx, dbDoc)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			// Keep the rest of the session usable if one file has gone missing
+			fmt.Printf("Warning: Could not restore document %s for session %s: %v\n", dbDoc.Filename, dbSession.ID, err)
+			continue
+		}
+		session.Documents = append(session.Documents, doc)
+	}
+
+	if len(session.Documents) > 0 {
+		session.Document = session.Documents[0]
+		session.DocumentId = session.Document.Id
+	}
+
+	dbMessages, err := m.messages.ListBySession(dbSession.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load session messages: %w", err)
+	}
+	for _, msg := range dbMessages {
+		session.Messages = append(session.Messages, &proto.ChatMessage{
+			Id:        msg.ID,
+			Role:      msg.Role,
+			Content:   msg.Content,
+			Timestamp: msg.CreatedAt.Unix(),
+		})
+	}
+
+	return session, pending, nil
+}
+
+// restoreDocument loads a document's stored chunks, or as a last resort
+// re-parses its original file
+func (m *SessionManager) restoreDocument(ctx context.Context, dbDoc repositories.DBDocument) (*proto.Document, error) {
+	doc, err := m.documents.GetContent(dbDoc.ID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrNotFound) {
+			fmt.Printf("Warning: Failed to load stored chunks for %s, re-parsing: %v\n", dbDoc.Filename, err)
+		}
+		if doc, err = m.parseDocument(ctx, dbDoc); err != nil {
+			return nil, err
+		}
+	}
+
+	m.vectorSearch.IndexDocument(doc)
+	return doc, nil
+}
+
+// parseDocument re-extracts a document from its original file and stores the
+// result so later rebuilds can skip parsing
+func (m *SessionManager) parseDocument(ctx context.Context, dbDoc repositories.DBDocument) (*proto.Document, error) {
+	if dbDoc.FilePath == "" {
+		return nil, fmt.Errorf("no stored chunks or file path")
+	}
+
+	doc, err := m.pdfService.ProcessPDF(ctx, dbDoc.ID, dbDoc.FilePath, dbDoc.Filename, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.documents.SaveContent(doc); err != nil {
+		fmt.Printf("Warning: Failed to store document content for %s: %v\n", dbDoc.Filename, err)
+	}
+
+	return doc, nil
+}
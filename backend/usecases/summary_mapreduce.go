@@ -0,0 +1,304 @@
+package usecases
+
+import (
+	"ai-pdf-assistant-backend/infrastructure/services"
+	"ai-pdf-assistant-backend/infrastructure/tokenizer"
+	"ai-pdf-assistant-backend/proto"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+)
+
+// summaryPart is a piece of text sent to the AI service in one summary call
+type summaryPart struct {
+	label string // e.g. "Pages 3-5"
+	text  string
+}
+
+// summarizeDocument summarizes a document of any length. Consecutive chunks are
+// grouped to fit the prompt, the groups are summarized concurrently, and the
+// partial summaries are reduced into the final one.
+func (uc *SummaryUseCase) summarizeDocument(ctx context.Context, doc *proto.Document) (*proto.DocumentSummary, error) {
+	chunks := doc.Chunks
+	if len(chunks) == 0 {
+		chunks = []*proto.Chunk{{Text: doc.Text, PageNumber: 1, EndPageNumber: doc.Pages}}
+	}
+
+	groups, sizes := groupChunks(chunks, uc.groupTokens)
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+
+	if len(groups) == 1 {
+		result, err := uc.aiService.GenerateSummary(ctx, groups[0].text)
+		if err != nil {
+			return nil, err
+		}
+		return documentSummary(doc, result, 100), nil
+	}
+
+	// Map: summarize every group. A failed group is skipped and lowers coverage
+	// rather than failing the whole summary.
+	results, errs := uc.summarizeParts(ctx, groups)
+	var partials []summaryPart
+	var partialResults []*services.SummaryResult
+	covered := 0
+	var firstErr error
+	for i, result := range results {
+		if errs[i] != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			fmt.Printf("Warning: Failed to summarize %s of %s: %v\n", groups[i].label, doc.Filename, errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		partials = append(partials, summaryPart{label: groups[i].label, text: formatPartial(result)})
+		partialResults = append(partialResults, result)
+		covered += sizes[i]
+	}
+	if len(partials) == 0 {
+		return nil, firstErr
+	}
+
+	// Reduce: merge the partial summaries into one
+	result, kept, err := uc.reduce(ctx, partials,
+		fmt.Sprintf("The following are summaries of consecutive sections of %s. Combine them into one summary of the whole document.", doc.Filename))
+	if err != nil {
+		return nil, err
+	}
+	mergeDetails(result, partialResults)
+
+	return documentSummary(doc, result, coveragePercent(int(float64(covered)*kept), total)), nil
+}
+
+// reduce merges partial summaries into one. While they are too long for a
+// single prompt, neighbouring partials are summarized together first. If they
+// still do not fit, the end of the input is cut; the share of the input that
+// was kept is returned so coverage can reflect the cut.
+func (uc *SummaryUseCase) reduce(ctx context.Context, partials []summaryPart, instruction string) (*services.SummaryResult, float64, error) {
+	for len(partials) > 1 && partsTokens(partials) > uc.groupTokens {
+		groups := groupParts(partials, uc.groupTokens)
+		if len(groups) == len(partials) {
+			break // Every partial already fills a prompt on its own
+		}
+
+		results, errs := uc.summarizeParts(ctx, groups)
+		partials = make([]summaryPart, len(groups))
+		for i, result := range results {
+			if errs[i] != nil {
+				return nil, 0, errs[i]
+			}
+			partials[i] = summaryPart{label: groups[i].label, text: formatPartial(result)}
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString(instruction)
+	builder.WriteString("\n")
+	for _, part := range partials {
+		fmt.Fprintf(&builder, "\n[%s]\n%s\n", part.label, part.text)
+	}
+
+	text, kept := builder.String(), 1.0
+	limit := services.SummaryTextTokens(uc.aiService.ModelInfo().ContextWindow)
+	if tokens := tokenizer.Count(text); tokens > limit {
+		fmt.Printf("Warning: Partial summaries take %d tokens, cutting them to %d\n", tokens, limit)
+		text, _ = tokenizer.Truncate(text, limit)
+		kept = float64(limit) / float64(tokens)
+	}
+
+	result, err := uc.aiService.GenerateSummary(ctx, text)
+	if err != nil {
+		return nil, 0, err
+	}
+	return result, kept, nil
+}
+
+// summarizeParts summarizes each part, with at most uc.workers calls to the AI
+// service in flight across all requests
+func (uc *SummaryUseCase) summarizeParts(ctx context.Context, parts []summaryPart) ([]*services.SummaryResult, []error) {
+	results := make([]*services.SummaryResult, len(parts))
+	errs := make([]error, len(parts))
+
+	var wg sync.WaitGroup
+	for i, part := range parts {
+		wg.Add(1)
+		go func(i int, part summaryPart) {
+			defer wg.Done()
+
+			select {
+			case uc.slots <- struct{}{}:
+				defer func() { <-uc.slots }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			results[i], errs[i] = uc.aiService.GenerateSummary(ctx, part.text)
+		}(i, part)
+	}
+	wg.Wait()
+
+	return results, errs
+}
+
+// documentSummary converts a provider result into the summary of one document
+func documentSummary(doc *proto.Document, result *services.SummaryResult, coverage float64) *proto.DocumentSummary {
+	for _, ref := range result.PageReferences {
+		ref.DocumentId = doc.Id
+	}
+
+	return &proto.DocumentSummary{
+		DocumentId:      doc.Id,
+		Filename:        doc.Filename,
+		Summary:         result.Summary,
+		KeyTakeaways:    result.KeyTakeaways,
+		MainTopics:      result.MainTopics,
+		Entities:        result.Entities,
+		PageReferences:  result.PageReferences,
+		CoveragePercent: coverage,
+	}
+}
+
+// formatPartial renders a partial summary as text for the reduce step, keeping
+// its key points and page references so they can survive into the final summary
+func formatPartial(result *services.SummaryResult) string {
+	var builder strings.Builder
+	builder.WriteString(result.Summary)
+	for _, takeaway := range result.KeyTakeaways {
+		builder.WriteString("\n- ")
+		builder.WriteString(takeaway)
+	}
+	for _, ref := range result.PageReferences {
+		fmt.Fprintf(&builder, "\n[Page %d] %s", ref.Page, ref.Point)
+	}
+	return builder.String()
+}
+
+// mergeDetails adds the entities found in partial summaries to the final
+// result, and their page references if the final result cites none
+func mergeDetails(result *services.SummaryResult, partials []*services.SummaryResult) {
+	seen := make(map[string]bool)
+	for _, entity := range result.Entities {
+		seen[strings.ToLower(entity.Name)] = true
+	}
+
+	collectReferences := len(result.PageReferences) == 0
+	for _, partial := range partials {
+		for _, entity := range partial.Entities {
+			if key := strings.ToLower(entity.Name); !seen[key] {
+				seen[key] = true
+				result.Entities = append(result.Entities, entity)
+			}
+		}
+		if collectReferences {
+			result.PageReferences = append(result.PageReferences, partial.PageReferences...)
+		}
+	}
+}
+
+// groupChunks joins consecutive chunks into parts of at most maxTokens tokens.
+// Each chunk is marked with its pages so summaries can cite them. It also
+// returns the text size of each part in tokens.
+func groupChunks(chunks []*proto.Chunk, maxTokens int) ([]summaryPart, []int) {
+	var parts []summaryPart
+	var sizes []int
+	var builder strings.Builder
+	size := 0
+	startPage, endPage := int32(0), int32(0)
+
+	flush := func() {
+		if builder.Len() == 0 {
+			return
+		}
+		parts = append(parts, summaryPart{label: pageLabel(startPage, endPage), text: builder.String()})
+		sizes = append(sizes, size)
+		builder.Reset()
+		size = 0
+	}
+
+	for _, chunk := range chunks {
+		tokens := tokenizer.Count(chunk.Text) + 6 // Text and page marker
+		if size+tokens > maxTokens && builder.Len() > 0 {
+			flush()
+		}
+		if builder.Len() == 0 {
+			startPage = chunk.PageNumber
+		}
+		fmt.Fprintf(&builder, "[%s]\n%s\n\n", pageLabel(chunk.PageNumber, chunk.EndPageNumber), chunk.Text)
+		size += tokens
+		endPage = chunk.EndPageNumber
+	}
+	flush()
+
+	return parts, sizes
+}
+
+// groupParts joins consecutive labelled summaries into parts of at most maxTokens tokens
+func groupParts(partials []summaryPart, maxTokens int) []summaryPart {
+	var groups []summaryPart
+	var builder strings.Builder
+	var firstLabel, lastLabel string
+	size := 0
+
+	flush := func() {
+		if builder.Len() == 0 {
+			return
+		}
+		label := firstLabel
+		if lastLabel != firstLabel {
+			label = firstLabel + " to " + lastLabel
+		}
+		groups = append(groups, summaryPart{label: label, text: builder.String()})
+		builder.Reset()
+		size = 0
+	}
+
+	for _, part := range partials {
+		entry := fmt.Sprintf("[%s]\n%s\n\n", part.label, part.text)
+		tokens := tokenizer.Count(entry)
+		if size+tokens > maxTokens && builder.Len() > 0 {
+			flush()
+		}
+		if builder.Len() == 0 {
+			firstLabel = part.label
+		}
+		builder.WriteString(entry)
+		size += tokens
+		lastLabel = part.label
+	}
+	flush()
+
+	return groups
+}
+
+// partsTokens returns the combined size of the parts in tokens
+func partsTokens(parts []summaryPart) int {
+	tokens := 0
+	for _, part := range parts {
+		tokens += tokenizer.Count(part.label) + tokenizer.Count(part.text)
+	}
+	return tokens
+}
+
+// pageLabel describes a page range
+func pageLabel(start int32, end int32) string {
+	if end <= start {
+		return fmt.Sprintf("Page %d", start)
+	}
+	return fmt.Sprintf("Pages %d-%d", start, end)
+}
+
+// coveragePercent returns covered as a percentage of total, to one decimal place
+func coveragePercent(covered int, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return math.Round(float64(covered)*1000/float64(total)) / 10
+}
@@ -1,33 +1,78 @@
 package usecases
 
 import (
-	"fmt"
 	"ai-pdf-assistant-backend/infrastructure/repositories"
 	"ai-pdf-assistant-backend/infrastructure/services"
 	"ai-pdf-assistant-backend/proto"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
 )
 
 // SummaryUseCase handles summary generation business logic
 type SummaryUseCase struct {
-	sessionRepo *repositories.SessionRepository
+	sessions    *SessionManager
 	aiService   services.AIService
+	summaries   repositories.SummaryStore
+	slots       chan struct{} // Bounds concurrent summary calls to the AI service
+	groupTokens int           // Most tokens of document text sent in one summary call
 }
 
-// NewSummaryUseCase creates a new summary use case
+// NewSummaryUseCase creates a new summary use case. Long documents are
+// summarized in sections of up to groupTokens tokens, at most workers at a
+// time. Sections are kept small enough for the model's context window.
 func NewSummaryUseCase(
-	sessionRepo *repositories.SessionRepository,
+	sessions *SessionManager,
 	aiService services.AIService,
+	summaries repositories.SummaryStore,
+	workers int,
+	groupTokens int,
 ) *SummaryUseCase {
+	// Leave room for the labels and instruction the reduce step adds
+	if fit := services.SummaryTextTokens(aiService.ModelInfo().ContextWindow) - 200; groupTokens > fit {
+		groupTokens = max(fit, 256)
+	}
+
 	return &SummaryUseCase{
-		sessionRepo: sessionRepo,
+		sessions:    sessions,
 		aiService:   aiService,
+		summaries:   summaries,
+		slots:       make(chan struct{}, workers),
+		groupTokens: groupTokens,
 	}
 }
 
-// GenerateSummary generates a summary for a document
-func (uc *SummaryUseCase) GenerateSummary(req *proto.SummaryRequest) (*proto.SummaryResponse, error) {
-	// Get session to access document
-	session, err := uc.sessionRepo.Get(req.SessionId)
+// Summary modes
+const (
+	SummaryModePerDocument = "per_document"
+	SummaryModeCombined    = "combined"
+)
+
+// GenerateSummary summarizes one, several or all documents in a session. In
+// combined mode the per-document summaries are merged into one overview.
+func (uc *SummaryUseCase) GenerateSummary(ctx context.Context, req *proto.SummaryRequest) (*proto.SummaryResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = SummaryModePerDocument
+	}
+	if mode != SummaryModePerDocument && mode != SummaryModeCombined {
+		return &proto.SummaryResponse{
+			Status: proto.Status_STATUS_ERROR,
+			Error: &proto.Error{
+				Code:    "INVALID_SUMMARY_MODE",
+				Message: fmt.Sprintf("Unknown summary mode %q, use %q or %q", req.Mode, SummaryModePerDocument, SummaryModeCombined),
+			},
+		}, nil
+	}
+
+	// Get session to access documents
+	session, err := uc.sessions.Get(ctx, req.SessionId)
 	if err != nil {
 		return &proto.SummaryResponse{
 			Status: proto.Status_STATUS_NOT_FOUND,
@@ -38,35 +83,257 @@ func (uc *SummaryUseCase) GenerateSummary(req *proto.SummaryRequest) (*proto.Sum
 		}, nil
 	}
 
-	// Get document text
-	doc := session.Document
-	if doc == nil {
-		return &proto.SummaryResponse{
+	docs, errResp := selectDocuments(session, req)
+	if errResp != nil {
+		return errResp, nil
+	}
+
+	// Uploads are processed in the background; wait until text is available
+	for _, doc := range docs {
+		if doc.Text == "" {
+			return &proto.SummaryResponse{
+				Status: proto.Status_STATUS_PROCESSING,
+				Error: &proto.Error{
+					Code:    "DOCUMENT_NOT_READY",
+					Message: fmt.Sprintf("%s is still being processed, please try again shortly", doc.Filename),
+				},
+			}, nil
+		}
+	}
+
+	// Serve a cached summary unless the caller asked for a fresh one
+	model := uc.aiService.ModelInfo()
+	key := uc.cacheKey(session.Id, mode, model, docs)
+	if !req.Refresh {
+		if resp := uc.cachedSummary(key); resp != nil {
+			return resp, nil
+		}
+	}
+
+	// A summary written partly by a fallback provider is not cached under the
+	// primary provider's key, so it is regenerated once that provider recovers
+	aiCtx, answeredBy := services.WithAnswerSource(ctx)
+	resp := uc.summarize(aiCtx, mode, docs)
+	if resp.Status == proto.Status_STATUS_SUCCESS && answeredBy.OnlyFrom(model) {
+		uc.storeSummary(key, session.Id, model, resp)
+	}
+
+	return resp, nil
+}
+
+// summarize generates the summary of the selected documents
+func (uc *SummaryUseCase) summarize(ctx context.Context, mode string, docs []*proto.Document) *proto.SummaryResponse {
+	// Summarize each document on its own so takeaways keep their source
+	resp := withEmptySummaryLists(&proto.SummaryResponse{
+		Status: proto.Status_STATUS_SUCCESS,
+		Mode:   mode,
+	})
+	covered, total := 0.0, 0
+	for _, doc := range docs {
+		docSummary, err := uc.summarizeDocument(ctx, doc)
+		if err != nil {
+			return &proto.SummaryResponse{
+				Status: proto.Status_STATUS_ERROR,
+				Error:  aiServiceError(ctx, err, "summarize "+doc.Filename),
+			}
+		}
+
+		resp.Documents = append(resp.Documents, docSummary)
+		resp.KeyTakeaways = append(resp.KeyTakeaways, docSummary.KeyTakeaways...)
+		for _, takeaway := range docSummary.KeyTakeaways {
+			resp.Takeaways = append(resp.Takeaways, &proto.Takeaway{
+				Text:       takeaway,
+				DocumentId: doc.Id,
+				Filename:   doc.Filename,
+			})
+		}
+		resp.PageReferences = append(resp.PageReferences, docSummary.PageReferences...)
+		resp.Entities = appendUniqueEntities(resp.Entities, docSummary.Entities...)
+		covered += docSummary.CoveragePercent * float64(len(doc.Text))
+		total += len(doc.Text)
+	}
+	resp.CoveragePercent = math.Round(covered*10/float64(total)) / 10
+
+	if len(resp.Documents) == 1 {
+		resp.Summary = resp.Documents[0].Summary
+		resp.MainTopics = resp.Documents[0].MainTopics
+		return resp
+	}
+
+	if mode == SummaryModeCombined {
+		// Merge the per-document summaries into one overview across documents
+		partials := make([]summaryPart, len(resp.Documents))
+		for i, docSummary := range resp.Documents {
+			partials[i] = summaryPart{label: "Document: " + docSummary.Filename, text: docSummary.Summary}
+		}
+		combined, kept, err := uc.reduce(ctx, partials,
+			fmt.Sprintf("The following are summaries of %d related documents. Summarize them together, comparing and connecting their content.", len(partials)))
+		if err != nil {
+			return &proto.SummaryResponse{
+				Status: proto.Status_STATUS_ERROR,
+				Error:  aiServiceError(ctx, err, "combine summaries"),
+			}
+		}
+		resp.Summary = combined.Summary
+		resp.MainTopics = combined.MainTopics
+		resp.CoveragePercent = math.Round(resp.CoveragePercent*kept*10) / 10
+		resp.Entities = appendUniqueEntities(resp.Entities, combined.Entities...)
+		return resp
+	}
+
+	// One section per document
+	var sections []string
+	for _, docSummary := range resp.Documents {
+		sections = append(sections, fmt.Sprintf("## %s\n\n%s", docSummary.Filename, docSummary.Summary))
+		resp.MainTopics = appendUnique(resp.MainTopics, docSummary.MainTopics...)
+	}
+	resp.Summary = strings.Join(sections, "\n\n")
+
+	return resp
+}
+
+// selectDocuments returns the session documents named by the request, in
+// session order, or every document when none are named
+func selectDocuments(session *proto.ChatSession, req *proto.SummaryRequest) ([]*proto.Document, *proto.SummaryResponse) {
+	wanted := make(map[string]bool)
+	if req.DocumentId != "" {
+		wanted[req.DocumentId] = true
+	}
+	for _, id := range req.DocumentIds {
+		wanted[id] = true
+	}
+
+	var docs []*proto.Document
+	for _, doc := range session.Documents {
+		if len(wanted) == 0 || wanted[doc.Id] {
+			docs = append(docs, doc)
+			delete(wanted, doc.Id)
+		}
+	}
+
+	for id := range wanted {
+		return nil, &proto.SummaryResponse{
+			Status: proto.Status_STATUS_NOT_FOUND,
+			Error: &proto.Error{
+				Code:    "DOCUMENT_NOT_FOUND",
+				Message: fmt.Sprintf("Document %s not found in session", id),
+			},
+		}
+	}
+	if len(docs) == 0 {
+		return nil, &proto.SummaryResponse{
 			Status: proto.Status_STATUS_ERROR,
 			Error: &proto.Error{
 				Code:    "DOCUMENT_NOT_FOUND",
 				Message: "Document not found in session",
 			},
-		}, nil
+		}
 	}
 
-	// Generate summary using AI service
-	summary, takeaways, topics, err := uc.aiService.GenerateSummary(doc.Text)
+	return docs, nil
+}
+
+// cacheKey identifies a summary of the documents' current content by the
+// session, mode, model and prompts that produced it
+func (uc *SummaryUseCase) cacheKey(sessionID string, mode string, model services.ModelInfo, docs []*proto.Document) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\n%s\n%s\n%s\n%s\n%d\n", services.SummaryPromptVersion, model.Provider, model.Model, sessionID, mode, uc.groupTokens)
+	for _, doc := range docs {
+		content := sha256.Sum256([]byte(doc.Text))
+		fmt.Fprintf(hash, "%s:%x\n", doc.Id, content)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cachedSummary returns the summary stored under key, or nil
+func (uc *SummaryUseCase) cachedSummary(key string) *proto.SummaryResponse {
+	stored, err := uc.summaries.Get(key)
 	if err != nil {
-		return &proto.SummaryResponse{
-			Status: proto.Status_STATUS_ERROR,
-			Error: &proto.Error{
-				Code:    "AI_SERVICE_ERROR",
-				Message: fmt.Sprintf("Failed to generate summary: %v", err),
-			},
-		}, nil
+		if !errors.Is(err, repositories.ErrNotFound) {
+			fmt.Printf("Warning: Failed to read cached summary: %v\n", err)
+		}
+		return nil
 	}
 
-	return &proto.SummaryResponse{
-		Status:        proto.Status_STATUS_SUCCESS,
-		Summary:       summary,
-		KeyTakeaways:  takeaways,
-		MainTopics:    topics,
-	}, nil
+	var resp proto.SummaryResponse
+	if err := json.Unmarshal(stored.Content, &resp); err != nil {
+		fmt.Printf("Warning: Discarding unreadable cached summary: %v\n", err)
+		return nil
+	}
+
+	resp.Cached = true
+	return withEmptySummaryLists(&resp) // Empty lists are omitted from the cached JSON
+}
+
+// storeSummary caches a generated summary; failures only cost a regeneration later
+func (uc *SummaryUseCase) storeSummary(key string, sessionID string, model services.ModelInfo, resp *proto.SummaryResponse) {
+	content, err := json.Marshal(resp)
+	if err == nil {
+		err = uc.summaries.Put(&repositories.StoredSummary{
+			Key:           key,
+			SessionID:     sessionID,
+			Provider:      model.Provider,
+			Model:         model.Model,
+			PromptVersion: services.SummaryPromptVersion,
+			Content:       content,
+			CreatedAt:     time.Now(),
+		})
+	}
+	if err != nil {
+		fmt.Printf("Warning: Failed to cache summary: %v\n", err)
+	}
 }
 
+// withEmptySummaryLists replaces missing lists with empty ones, so they encode as [] rather than null
+func withEmptySummaryLists(resp *proto.SummaryResponse) *proto.SummaryResponse {
+	if resp.KeyTakeaways == nil {
+		resp.KeyTakeaways = []string{}
+	}
+	if resp.MainTopics == nil {
+		resp.MainTopics = []string{}
+	}
+	if resp.Takeaways == nil {
+		resp.Takeaways = []*proto.Takeaway{}
+	}
+	if resp.Entities == nil {
+		resp.Entities = []*proto.Entity{}
+	}
+	if resp.PageReferences == nil {
+		resp.PageReferences = []*proto.PageReference{}
+	}
+	return resp
+}
+
+// appendUnique appends the values not already present, ignoring case
+func appendUnique(values []string, more ...string) []string {
+	for _, value := range more {
+		duplicate := false
+		for _, existing := range values {
+			if strings.EqualFold(existing, value) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// appendUniqueEntities appends the entities whose names are not already present, ignoring case
+func appendUniqueEntities(entities []*proto.Entity, more ...*proto.Entity) []*proto.Entity {
+	for _, entity := range more {
+		duplicate := false
+		for _, existing := range entities {
+			if strings.EqualFold(existing.Name, entity.Name) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			entities = append(entities, entity)
+		}
+	}
+	return entities
+}
@@ -195,15 +195,15 @@ export default function ExplanationPanel({
                         <span className="text-xs text-gray-500 dark:text-gray-400">Sources:</span>
                         {citations.map((citation, index) => (
                             <button
-                                key={index}
+                                key={citation.chunk_id || index}
                                 onClick={() => handleCitationClick(citation.page)}
                                 className="inline-flex items-center px-2 py-1 text-xs bg-blue-100 dark:bg-blue-900/50 text-blue-700 dark:text-blue-300 rounded-full hover:bg-blue-200 dark:hover:bg-blue-800 transition-colors cursor-pointer"
-                                title={citation.text}
+                                title={citation.filename ? `${citation.filename}: ${citation.snippet}` : citation.snippet}
                             >
                                 <svg className="w-3 h-3 mr-1" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                                     <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12h6m-6 4h6m2 5H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
                                 </svg>
-                                Page {citation.page}
+                                {citation.label || `Page ${citation.page}`}
                             </button>
                         ))}
                     </div>
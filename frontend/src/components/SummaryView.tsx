@@ -55,6 +55,11 @@ export default function SummaryView({ sessionId }: SummaryViewProps) {
                   <ReactMarkdown>{summary.summary}</ReactMarkdown>
                 </div>
               </div>
+              {summary.coverage_percent !== undefined && summary.coverage_percent < 100 && (
+                <p className="mt-2 text-xs text-amber-600 dark:text-amber-400">
+                  Based on {summary.coverage_percent}% of the document text; some sections could not be summarized.
+                </p>
+              )}
             </div>
 
             {/* Key Takeaways */}
@@ -63,7 +68,14 @@ export default function SummaryView({ sessionId }: SummaryViewProps) {
                 <h3 className="text-lg font-semibold text-gray-700 dark:text-gray-300 mb-2">Key Takeaways</h3>
                 <ul className="list-disc list-inside space-y-2 bg-gray-50 dark:bg-gray-700 rounded-lg p-4">
                   {summary.key_takeaways.map((takeaway, index) => (
-                    <li key={index} className="text-gray-800 dark:text-gray-200">{takeaway}</li>
+                    <li key={index} className="text-gray-800 dark:text-gray-200">
+                      {takeaway}
+                      {(summary.documents?.length ?? 0) > 1 && summary.takeaways?.[index] && (
+                        <span className="ml-2 text-xs text-gray-500 dark:text-gray-400">
+                          ({summary.takeaways[index].filename})
+                        </span>
+                      )}
+                    </li>
                   ))}
                 </ul>
               </div>
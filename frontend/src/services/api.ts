@@ -18,11 +18,32 @@ export interface UploadResponse {
   message: string;
 }
 
+export interface Highlight {
+  start: number;
+  end: number;
+}
+
 export interface Citation {
+  document_id: string;
+  filename: string;
+  chunk_id: string;
   page: number;
+  end_page: number;
+  label: string;
+  start_offset: number;
+  end_offset: number;
+  snippet: string;
+  highlights?: Highlight[];
+  marker: number;
+  sentences?: number[];
+}
+
+export interface AnswerSentence {
   text: string;
-  document_id?: string;
-  filename?: string;
+  start: number;
+  end: number;
+  citations?: number[];
+  supported: boolean;
 }
 
 export interface SessionDocument {
@@ -40,8 +61,13 @@ export interface ChatResponse {
   response: string;
   session_id: string;
   answer_found: boolean;
+  confidence?: number;
   relevant_chunks?: string[];
   citations?: Citation[];
+  sentences?: AnswerSentence[];
+  provider?: string;
+  model?: string;
+  rewritten_query?: string;
 }
 
 export interface ChatMessage {
@@ -51,12 +77,85 @@ export interface ChatMessage {
   timestamp?: number;
 }
 
+export interface DocumentSummary {
+  document_id: string;
+  filename: string;
+  summary: string;
+  key_takeaways: string[];
+  main_topics: string[];
+  entities: Entity[] | null;
+  page_references: PageReference[] | null;
+  coverage_percent: number;
+}
+
+export interface Entity {
+  name: string;
+  type?: string;
+}
+
+export interface PageReference {
+  document_id?: string;
+  page: number;
+  point: string;
+}
+
+export interface Takeaway {
+  text: string;
+  document_id: string;
+  filename: string;
+}
+
 export interface SummaryResponse {
   summary: string;
   key_takeaways: string[];
   main_topics: string[];
+  mode?: 'per_document' | 'combined';
+  documents?: DocumentSummary[];
+  takeaways?: Takeaway[];
+  entities?: Entity[];
+  page_references?: PageReference[];
+  coverage_percent?: number;
+  cached?: boolean;
+}
+
+export interface SummaryOptions {
+  documentIds?: string[];
+  mode?: 'per_document' | 'combined';
+  refresh?: boolean;
+}
+
+export interface DocumentStatus {
+  id: string;
+  filename: string;
+  pages: number;
+  chunks: number;
+  status: 'queued' | 'processing' | 'ready' | 'failed';
+  progress: {
+    pages_done: number;
+    pages_total: number;
+  };
+  error?: string;
 }
 
+export const getDocumentStatus = async (documentId: string): Promise<DocumentStatus> => {
+  const response = await api.get<DocumentStatus>(`/pdf/status/${documentId}`);
+  return response.data;
+};
+
+// Uploads are processed in the background; poll until the document is ready
+const waitForProcessing = async (upload: UploadResponse): Promise<UploadResponse> => {
+  while (true) {
+    const status = await getDocumentStatus(upload.document_id);
+    if (status.status === 'ready') {
+      return { ...upload, pages: status.pages, chunks: status.chunks };
+    }
+    if (status.status === 'failed') {
+      throw new Error(status.error || 'Failed to process PDF');
+    }
+    await new Promise((resolve) => setTimeout(resolve, 1000));
+  }
+};
+
 export const uploadPDF = async (file: File): Promise<UploadResponse> => {
   const formData = new FormData();
   formData.append('pdf', file);
@@ -67,7 +166,7 @@ export const uploadPDF = async (file: File): Promise<UploadResponse> => {
     },
   });
 
-  return response.data;
+  return waitForProcessing(response.data);
 };
 
 export const addPDFToSession = async (sessionId: string, file: File): Promise<UploadResponse> => {
@@ -80,7 +179,7 @@ export const addPDFToSession = async (sessionId: string, file: File): Promise<Up
     },
   });
 
-  return response.data;
+  return waitForProcessing(response.data);
 };
 
 export const getSessionDocuments = async (sessionId: string): Promise<SessionDocument[]> => {
@@ -106,9 +205,12 @@ export const getChatHistory = async (sessionId: string): Promise<ChatMessage[]>
   return response.data.messages || [];
 };
 
-export const generateSummary = async (sessionId: string): Promise<SummaryResponse> => {
+export const generateSummary = async (sessionId: string, options: SummaryOptions = {}): Promise<SummaryResponse> => {
   const response = await api.post<SummaryResponse>('/pdf/summary', {
     session_id: sessionId,
+    document_ids: options.documentIds,
+    mode: options.mode,
+    refresh: options.refresh,
   });
 
   return response.data;
@@ -178,7 +280,12 @@ export const streamMessage = async (
                 response: parsed.response,
                 session_id: parsed.session_id,
                 answer_found: parsed.answer_found,
+                confidence: parsed.confidence,
                 citations: parsed.citations,
+                sentences: parsed.sentences,
+                provider: parsed.provider,
+                model: parsed.model,
+                rewritten_query: parsed.rewritten_query,
               });
             } else if (parsed.message !== undefined) {
               callbacks.onError(parsed.message);